@@ -1,11 +1,14 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"time"
 
 	"golang.org/x/exp/slog"
@@ -19,6 +22,11 @@ const (
 
 	defaultConfigFilename       = "rssole.json"
 	defaultReadCacheFilename    = "rssole_readcache.json"
+	defaultArchiveFilename      = "rssole_archive.json"
+	defaultStarredFilename      = "rssole_starred.json"
+	defaultIDsFilename          = "rssole_ids.json"
+	defaultImagesDirectory      = "rssole_images"
+	defaultImageKeyFilename     = "rssole_image.key"
 	oldDefaultConfigFilename    = "feeds.json"
 	oldDefaultReadCacheFilename = "readcache.json"
 )
@@ -46,16 +54,31 @@ func getFeedsFileConfigSection(filename string) (rssole.ConfigSection, error) {
 	return cfgFile.Config, nil
 }
 
-func handleFlags(configFilename, configReadCacheFilename *string) {
+func handleFlags(files *rssole.Files) {
 	originalUsage := flag.Usage
 	flag.Usage = func() {
 		fmt.Println("RSSOLE version", rssole.Version)
 		fmt.Println()
 		originalUsage()
+		fmt.Println()
+		fmt.Println("Commands:")
+		fmt.Println("  import-opml <file>")
+		fmt.Println("        add the feeds in an OPML file to the config file (- for stdin)")
+		fmt.Println("  export-opml [file]")
+		fmt.Println("        write the feeds in the config file as OPML (default stdout)")
+		fmt.Println("  hash-password")
+		fmt.Println("        read a password from stdin and print its hash for the auth users config")
+		fmt.Println("  validate-config")
+		fmt.Println("        fetch every feed in the config file and report any that fail")
 	}
 
-	flag.StringVar(configFilename, "c", defaultConfigFilename, "config filename, must be writable")
-	flag.StringVar(configReadCacheFilename, "r", defaultReadCacheFilename, "readcache filename, must be writable")
+	flag.StringVar(&files.Config, "c", defaultConfigFilename, "config filename, must be writable")
+	flag.StringVar(&files.ReadCache, "r", defaultReadCacheFilename, "readcache filename, must be writable")
+	flag.StringVar(&files.Archive, "a", defaultArchiveFilename, "item archive filename, must be writable")
+	flag.StringVar(&files.Starred, "s", defaultStarredFilename, "starred items filename, must be writable")
+	flag.StringVar(&files.IDs, "i", defaultIDsFilename, "numeric ids filename (for the Fever API), must be writable")
+	flag.StringVar(&files.Images, "m", defaultImagesDirectory, "image cache directory, must be writable (empty for no cache)")
+	flag.StringVar(&files.ImageKey, "k", defaultImageKeyFilename, "image proxy key filename, must be writable")
 	flag.Parse()
 }
 
@@ -76,39 +99,139 @@ func loadConfig(configFilename string) (rssole.ConfigSection, error) {
 	return cfg, nil
 }
 
+func importOPML(configFilename, opmlFilename string) error {
+	r := os.Stdin
+
+	if opmlFilename != "-" {
+		opmlFile, err := os.Open(opmlFilename)
+		if err != nil {
+			return fmt.Errorf("error opening file: %w", err)
+		}
+		defer opmlFile.Close()
+
+		r = opmlFile
+	}
+
+	added, err := rssole.ImportOPML(configFilename, r)
+	if err != nil {
+		return fmt.Errorf("error importing OPML: %w", err)
+	}
+
+	slog.Info("Imported OPML", "filename", opmlFilename, "added", added)
+
+	return nil
+}
+
+func exportOPML(configFilename, opmlFilename string) error {
+	w := os.Stdout
+
+	if opmlFilename != "" && opmlFilename != "-" {
+		opmlFile, err := os.Create(opmlFilename)
+		if err != nil {
+			return fmt.Errorf("error creating file: %w", err)
+		}
+		defer opmlFile.Close()
+
+		w = opmlFile
+	}
+
+	if err := rssole.ExportOPML(configFilename, w); err != nil {
+		return fmt.Errorf("error exporting OPML: %w", err)
+	}
+
+	return nil
+}
+
+func hashPassword() error {
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error reading password: %w", err)
+	}
+
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		return errors.New("password must not be empty")
+	}
+
+	hash, err := rssole.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("error hashing password: %w", err)
+	}
+
+	fmt.Println(hash)
+
+	return nil
+}
+
+// runCommand runs any subcommand given after the flags.
+// Returns false if there was no subcommand to run.
+func runCommand(configFilename string) (bool, error) {
+	switch flag.Arg(0) {
+	case "":
+		return false, nil
+	case "import-opml":
+		if flag.NArg() != 2 {
+			return true, errors.New("import-opml requires an OPML filename")
+		}
+
+		return true, importOPML(configFilename, flag.Arg(1))
+	case "export-opml":
+		return true, exportOPML(configFilename, flag.Arg(1))
+	case "hash-password":
+		return true, hashPassword()
+	case "validate-config":
+		if err := rssole.ValidateConfig(configFilename, os.Stdout); err != nil {
+			return true, fmt.Errorf("error validating config: %w", err)
+		}
+
+		return true, nil
+	default:
+		return true, fmt.Errorf("unknown command %q", flag.Arg(0))
+	}
+}
+
 func main() {
-	var configFilename, configReadCacheFilename string
+	var files rssole.Files
 
-	handleFlags(&configFilename, &configReadCacheFilename)
+	handleFlags(&files)
 
 	// If the config file doesn't exist, try the old default name.
-	if _, err := os.Stat(configFilename); errors.Is(err, os.ErrNotExist) {
-		if configFilename != oldDefaultConfigFilename {
+	if _, err := os.Stat(files.Config); errors.Is(err, os.ErrNotExist) {
+		if files.Config != oldDefaultConfigFilename {
 			if _, err := os.Stat(oldDefaultConfigFilename); err == nil {
 				slog.Info("Falling back to old config filename:", "filename", oldDefaultConfigFilename)
-				configFilename = oldDefaultConfigFilename
+				files.Config = oldDefaultConfigFilename
 			}
 		}
 	}
 
 	// If the readcache file doesn't exist, try the old default name.
-	if _, err := os.Stat(configReadCacheFilename); errors.Is(err, os.ErrNotExist) {
-		if configReadCacheFilename != oldDefaultReadCacheFilename {
+	if _, err := os.Stat(files.ReadCache); errors.Is(err, os.ErrNotExist) {
+		if files.ReadCache != oldDefaultReadCacheFilename {
 			if _, err := os.Stat(oldDefaultReadCacheFilename); err == nil {
 				slog.Info("Falling back to old readcache filename:", "filename", oldDefaultReadCacheFilename)
-				configReadCacheFilename = oldDefaultReadCacheFilename
+				files.ReadCache = oldDefaultReadCacheFilename
 			}
 		}
 	}
 
-	cfg, err := loadConfig(configFilename)
+	if ran, err := runCommand(files.Config); ran {
+		if err != nil {
+			slog.Error("command failed", "command", flag.Arg(0), "error", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	cfg, err := loadConfig(files.Config)
 	if err != nil {
-		slog.Error("unable to get config section of config file", "filename", configFilename, "error", err)
+		slog.Error("unable to get config section of config file", "filename", files.Config, "error", err)
 		os.Exit(1)
 	}
 
 	// Start service
-	err = rssole.Start(configFilename, configReadCacheFilename, cfg.Listen, time.Duration(cfg.UpdateSeconds)*time.Second)
+	err = rssole.Start(files, cfg.Listen, time.Duration(cfg.UpdateSeconds)*time.Second)
 	if err != nil {
 		slog.Error("rssole.Start exited with error", "error", err)
 		os.Exit(1)
@@ -0,0 +1,349 @@
+package rssole
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// The JSON API (/api/v1) mirrors the htmx endpoints for scripts and other clients.
+
+// Every API request counts as client activity, as a script may be the only client.
+func (s *Service) registerAPIRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/feeds", s.active(s.apiFeedsList))
+	mux.HandleFunc("POST /api/v1/feeds", s.active(s.apiFeedsCreate))
+	mux.HandleFunc("GET /api/v1/feeds/{feed}", s.active(s.apiFeedGet))
+	mux.HandleFunc("PUT /api/v1/feeds/{feed}", s.active(s.apiFeedUpdate))
+	mux.HandleFunc("DELETE /api/v1/feeds/{feed}", s.active(s.apiFeedDelete))
+	mux.HandleFunc("GET /api/v1/feeds/{feed}/items", s.active(s.apiItemsList))
+	mux.HandleFunc("GET /api/v1/feeds/{feed}/items/{item}", s.active(s.apiItemGet))
+	mux.HandleFunc("POST /api/v1/feeds/{feed}/read", s.active(s.apiFeedMarkRead))
+	mux.HandleFunc("GET /api/v1/settings", s.active(s.apiSettingsGet))
+	mux.HandleFunc("PUT /api/v1/settings", s.active(s.apiSettingsUpdate))
+}
+
+type apiFeed struct {
+	ID            string        `json:"id"`
+	URL           string        `json:"url"`
+	Name          string        `json:"name,omitempty"`
+	Category      string        `json:"category,omitempty"`
+	Scrape        *scrape       `json:"scrape,omitempty"`
+	Filters       []*filterRule `json:"filters,omitempty"`
+	UpdateSeconds int           `json:"update_seconds,omitempty"`
+	FullContent   bool          `json:"full_content,omitempty"`
+	Title         string        `json:"title"`
+	Link          string        `json:"link,omitempty"`
+	UnreadCount   int           `json:"unread_count"`
+	HasError      bool          `json:"has_error"`
+}
+
+type apiItem struct {
+	ID          string     `json:"id"`
+	FeedID      string     `json:"feed_id"`
+	Title       string     `json:"title"`
+	Link        string     `json:"link,omitempty"`
+	Summary     string     `json:"summary,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Images      []string   `json:"images,omitempty"`
+	Categories  []string   `json:"categories,omitempty"`
+	Published   *time.Time `json:"published,omitempty"`
+	Updated     *time.Time `json:"updated,omitempty"`
+	Unread      bool       `json:"unread"`
+	Starred     bool       `json:"starred"`
+}
+
+type apiSettings struct {
+	Listen        string `json:"listen"`
+	UpdateSeconds int    `json:"update_seconds"`
+	ArchiveDays   int    `json:"archive_days"`
+}
+
+type apiMarkRead struct {
+	IDs []string `json:"ids"` // empty marks everything read
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// Caller must hold f.mu.RLock.
+func newAPIFeed(f *feed) apiFeed {
+	return apiFeed{
+		ID:            f.ID(),
+		URL:           f.URL,
+		Name:          f.Name,
+		Category:      f.Category,
+		Scrape:        f.Scrape,
+		Filters:       f.Filters,
+		UpdateSeconds: f.UpdateSeconds,
+		FullContent:   f.FullContent,
+		Title:         f.Title(),
+		Link:          f.Link(),
+		UnreadCount:   f.UnreadItemCount(),
+		HasError:      f.HasRecentError(),
+	}
+}
+
+// Caller must hold f.mu.RLock. The description is only included when asked
+// for, as it can be large.
+func (s *Service) newAPIItem(f *feed, i *wrappedItem, withDescription bool) apiItem {
+	item := apiItem{
+		ID:         i.ID(),
+		FeedID:     f.ID(),
+		Title:      i.Title,
+		Link:       i.Link,
+		Summary:    i.Summary(),
+		Images:     i.Images(),
+		Categories: i.Categories,
+		Published:  i.PublishedParsed,
+		Updated:    i.UpdatedParsed,
+		Unread:     i.IsUnread,
+		Starred:    s.stars.IsStarred(i.ID()),
+	}
+
+	if withDescription {
+		item.Description = i.Description()
+	}
+
+	return item
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("writeJSON", "error", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, apiError{Error: msg})
+}
+
+// apiFeedFromPath returns the feed named in the path, writing a 404 if not found.
+func (s *Service) apiFeedFromPath(w http.ResponseWriter, req *http.Request) *feed {
+	f := s.feeds.getFeedByID(req.PathValue("feed"))
+	if f == nil {
+		writeJSONError(w, http.StatusNotFound, "feed not found")
+	}
+
+	return f
+}
+
+// decodeAPIFeed reads a feed definition from the body, in the same form as rssole.json.
+func decodeAPIFeed(w http.ResponseWriter, req *http.Request) *feed {
+	fd := &feed{}
+	if err := json.NewDecoder(req.Body).Decode(fd); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid feed json")
+
+		return nil
+	}
+
+	if fd.URL == "" {
+		writeJSONError(w, http.StatusBadRequest, "url is required")
+
+		return nil
+	}
+
+	if err := checkUpdateSeconds(fd.UpdateSeconds); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+
+		return nil
+	}
+
+	for _, rule := range fd.Filters {
+		if err := rule.Validate(); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+
+			return nil
+		}
+	}
+
+	return fd
+}
+
+func (s *Service) saveFeedsFileOrLog() {
+	if err := s.feeds.saveFeedsFile(); err != nil {
+		slog.Error("saveFeedsFile", "error", err)
+	}
+}
+
+func (s *Service) apiFeedsList(w http.ResponseWriter, _ *http.Request) {
+	feeds := []apiFeed{}
+
+	for _, f := range s.feeds.All() {
+		f.mu.RLock()
+		feeds = append(feeds, newAPIFeed(f))
+		f.mu.RUnlock()
+	}
+
+	writeJSON(w, http.StatusOK, feeds)
+}
+
+func (s *Service) apiFeedsCreate(w http.ResponseWriter, req *http.Request) {
+	fd := decodeAPIFeed(w, req)
+	if fd == nil {
+		return
+	}
+
+	if s.feeds.list.FindByURL(fd.URL) != nil {
+		writeJSONError(w, http.StatusConflict, "feed already exists")
+
+		return
+	}
+
+	s.addFeed(fd)
+	s.saveFeedsFileOrLog()
+
+	fd.mu.RLock()
+	defer fd.mu.RUnlock()
+
+	writeJSON(w, http.StatusCreated, newAPIFeed(fd))
+}
+
+func (s *Service) apiFeedGet(w http.ResponseWriter, req *http.Request) {
+	f := s.apiFeedFromPath(w, req)
+	if f == nil {
+		return
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	writeJSON(w, http.StatusOK, newAPIFeed(f))
+}
+
+func (s *Service) apiFeedUpdate(w http.ResponseWriter, req *http.Request) {
+	fd := decodeAPIFeed(w, req)
+	if fd == nil {
+		return
+	}
+
+	f := s.updateFeed(req.PathValue("feed"), fd)
+	if f == nil {
+		writeJSONError(w, http.StatusNotFound, "feed not found")
+
+		return
+	}
+
+	s.saveFeedsFileOrLog()
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	writeJSON(w, http.StatusOK, newAPIFeed(f))
+}
+
+func (s *Service) apiFeedDelete(w http.ResponseWriter, req *http.Request) {
+	if f := s.apiFeedFromPath(w, req); f == nil {
+		return
+	}
+
+	s.deleteFeed(req.PathValue("feed"))
+	s.saveFeedsFileOrLog()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) apiItemsList(w http.ResponseWriter, req *http.Request) {
+	f := s.apiFeedFromPath(w, req)
+	if f == nil {
+		return
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	items := []apiItem{}
+	for _, i := range f.Items() {
+		items = append(items, s.newAPIItem(f, i, false))
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (s *Service) apiItemGet(w http.ResponseWriter, req *http.Request) {
+	f := s.apiFeedFromPath(w, req)
+	if f == nil {
+		return
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	id := req.PathValue("item")
+
+	for _, i := range f.Items() {
+		if i.ID() == id {
+			writeJSON(w, http.StatusOK, s.newAPIItem(f, i, true))
+
+			return
+		}
+	}
+
+	writeJSONError(w, http.StatusNotFound, "item not found")
+}
+
+func (s *Service) apiFeedMarkRead(w http.ResponseWriter, req *http.Request) {
+	f := s.apiFeedFromPath(w, req)
+	if f == nil {
+		return
+	}
+
+	var body apiMarkRead
+
+	// an empty body is fine, it means mark everything read
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		writeJSONError(w, http.StatusBadRequest, "invalid mark read json")
+
+		return
+	}
+
+	ids := map[string]bool{}
+	for _, id := range body.IDs {
+		ids[id] = true
+	}
+
+	marked := s.markRead(f, func(i *wrappedItem) bool {
+		return i.IsUnread && (len(ids) == 0 || ids[i.ID()])
+	})
+
+	writeJSON(w, http.StatusOK, map[string]int{"marked": marked})
+}
+
+func (s *Service) currentAPISettings() apiSettings {
+	return apiSettings{
+		Listen:        s.feeds.Config.Listen,
+		UpdateSeconds: s.feeds.Config.UpdateSeconds,
+		ArchiveDays:   s.feeds.Config.ArchiveDays,
+	}
+}
+
+func (s *Service) apiSettingsGet(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.currentAPISettings())
+}
+
+func (s *Service) apiSettingsUpdate(w http.ResponseWriter, req *http.Request) {
+	body := s.currentAPISettings()
+
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid settings json")
+
+		return
+	}
+
+	if err := s.changeSettings(body.UpdateSeconds, body.ArchiveDays); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	s.saveFeedsFileOrLog()
+
+	writeJSON(w, http.StatusOK, s.currentAPISettings())
+}
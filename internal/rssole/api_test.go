@@ -0,0 +1,217 @@
+package rssole
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// newAPITestService returns a service with one feed holding two unread items,
+// and a mux with the API routes registered.
+func newAPITestService(t *testing.T) (*Service, *feed, *http.ServeMux) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	svc := NewService()
+	svc.startOnce.Do(func() {}) // requests mustn't start fetching the test feed
+	svc.readLut.Filename = filepath.Join(dir, "readcache.json")
+	svc.stars.Filename = filepath.Join(dir, "starred.json")
+	svc.ids.Filename = filepath.Join(dir, "ids.json")
+	svc.feeds.filename = filepath.Join(dir, "rssole.json")
+	svc.feeds.UpdateTime = time.Hour
+	svc.feeds.Config.UpdateSeconds = 900
+
+	f := &feed{URL: "http://example.com/api_feed", Name: "API Feed", Category: "Cat", feed: &gofeed.Feed{}}
+	f.Init()
+
+	items := []*wrappedItem{
+		{IsUnread: true, Feed: f, Item: &gofeed.Item{Title: "Item 1", Link: "http://example.com/1", Description: "Description 1"}},
+		{IsUnread: true, Feed: f, Item: &gofeed.Item{Title: "Item 2", Link: "http://example.com/2"}},
+	}
+	f.wrappedItems.Store(&items)
+
+	svc.feeds.list.Add(f)
+
+	mux := http.NewServeMux()
+	svc.registerAPIRoutes(mux)
+
+	return svc, f, mux
+}
+
+func apiRequest(t *testing.T, mux *http.ServeMux, method, path, body string, expectedStatus int, v any) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != expectedStatus {
+		t.Fatalf("%s %s returned wrong status code: got %v want %v (%s)",
+			method, path, rr.Code, expectedStatus, rr.Body.String())
+	}
+
+	if v != nil {
+		if err := json.Unmarshal(rr.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s returned invalid json: %v", method, path, err)
+		}
+	}
+}
+
+func TestAPI_FeedsAndItems(t *testing.T) {
+	_, f, mux := newAPITestService(t)
+
+	var feeds []apiFeed
+	apiRequest(t, mux, http.MethodGet, "/api/v1/feeds", "", http.StatusOK, &feeds)
+
+	if len(feeds) != 1 || feeds[0].ID != f.ID() || feeds[0].Title != "API Feed" || feeds[0].UnreadCount != 2 {
+		t.Fatal("unexpected feeds", feeds)
+	}
+
+	var items []apiItem
+	apiRequest(t, mux, http.MethodGet, "/api/v1/feeds/"+f.ID()+"/items", "", http.StatusOK, &items)
+
+	if len(items) != 2 || items[0].Title != "Item 1" || !items[0].Unread {
+		t.Fatal("unexpected items", items)
+	}
+
+	if items[0].Description != "" {
+		t.Error("expected no description in item list")
+	}
+
+	var item apiItem
+	apiRequest(t, mux, http.MethodGet, "/api/v1/feeds/"+f.ID()+"/items/"+items[0].ID, "", http.StatusOK, &item)
+
+	if !strings.Contains(item.Description, "Description 1") {
+		t.Error("expected description in single item, got", item.Description)
+	}
+
+	apiRequest(t, mux, http.MethodGet, "/api/v1/feeds/"+f.ID()+"/items/nope", "", http.StatusNotFound, nil)
+	apiRequest(t, mux, http.MethodGet, "/api/v1/feeds/nope/items", "", http.StatusNotFound, nil)
+}
+
+func TestAPI_RecordsActivity(t *testing.T) {
+	svc, _, mux := newAPITestService(t)
+
+	apiRequest(t, mux, http.MethodGet, "/api/v1/feeds", "", http.StatusOK, nil)
+
+	svc.lastActivityMu.Lock()
+	defer svc.lastActivityMu.Unlock()
+
+	if svc.lastActivity.IsZero() {
+		t.Fatal("expected an API request to count as client activity")
+	}
+}
+
+func TestAPI_MarkRead(t *testing.T) {
+	svc, f, mux := newAPITestService(t)
+
+	item1 := f.Items()[0]
+
+	var marked map[string]int
+	apiRequest(t, mux, http.MethodPost, "/api/v1/feeds/"+f.ID()+"/read", `{"ids":["`+item1.ID()+`"]}`, http.StatusOK, &marked)
+
+	if marked["marked"] != 1 || item1.IsUnread || svc.readLut.IsUnread(item1.MarkReadID()) {
+		t.Fatal("expected item 1 to be marked read")
+	}
+
+	if !f.Items()[1].IsUnread {
+		t.Fatal("expected item 2 to still be unread")
+	}
+
+	// no ids means everything
+	apiRequest(t, mux, http.MethodPost, "/api/v1/feeds/"+f.ID()+"/read", "", http.StatusOK, &marked)
+
+	if marked["marked"] != 1 || f.UnreadItemCount() != 0 {
+		t.Fatal("expected everything to be marked read")
+	}
+}
+
+func TestAPI_CreateUpdateDeleteFeed(t *testing.T) {
+	svc, _, mux := newAPITestService(t)
+
+	var created apiFeed
+	apiRequest(t, mux, http.MethodPost, "/api/v1/feeds",
+		`{"url":"http://example.com/new_feed","name":"New","category":"Cat"}`, http.StatusCreated, &created)
+
+	newFeed := svc.feeds.getFeedByID(created.ID)
+	if newFeed == nil || newFeed.Name != "New" {
+		t.Fatal("expected feed to be created")
+	}
+
+	apiRequest(t, mux, http.MethodPost, "/api/v1/feeds", `{"url":"http://example.com/new_feed"}`, http.StatusConflict, nil)
+	apiRequest(t, mux, http.MethodPost, "/api/v1/feeds", `{"name":"No URL"}`, http.StatusBadRequest, nil)
+	apiRequest(t, mux, http.MethodPost, "/api/v1/feeds",
+		`{"url":"http://example.com/too_often","update_seconds":60}`, http.StatusBadRequest, nil)
+
+	var updated apiFeed
+	apiRequest(t, mux, http.MethodPut, "/api/v1/feeds/"+created.ID,
+		`{"url":"http://example.com/new_feed","name":"Renamed","update_seconds":3600}`, http.StatusOK, &updated)
+
+	if updated.Name != "Renamed" || updated.Category != "" || updated.UpdateSeconds != 3600 {
+		t.Fatal("expected feed to be updated", updated)
+	}
+
+	apiRequest(t, mux, http.MethodPut, "/api/v1/feeds/"+created.ID,
+		`{"url":"http://example.com/new_feed","filters":[{"field":"title","match":"sponsored","action":"hide"}]}`,
+		http.StatusOK, &updated)
+
+	if len(updated.Filters) != 1 || updated.Filters[0].Match != "sponsored" || updated.Filters[0].Action != "hide" {
+		t.Fatal("expected the filters to be updated", updated.Filters)
+	}
+
+	// leaving the filters out keeps them
+	apiRequest(t, mux, http.MethodPut, "/api/v1/feeds/"+created.ID, `{"url":"http://example.com/new_feed"}`, http.StatusOK, nil)
+
+	var fetched apiFeed
+	apiRequest(t, mux, http.MethodGet, "/api/v1/feeds/"+created.ID, "", http.StatusOK, &fetched)
+
+	if len(fetched.Filters) != 1 || fetched.Filters[0].Match != "sponsored" {
+		t.Fatal("expected the filters to be kept", fetched.Filters)
+	}
+
+	var cleared apiFeed
+	apiRequest(t, mux, http.MethodPut, "/api/v1/feeds/"+created.ID,
+		`{"url":"http://example.com/new_feed","filters":[]}`, http.StatusOK, &cleared)
+
+	if len(cleared.Filters) != 0 {
+		t.Fatal("expected an empty list to clear the filters", cleared.Filters)
+	}
+
+	apiRequest(t, mux, http.MethodDelete, "/api/v1/feeds/"+created.ID, "", http.StatusNoContent, nil)
+
+	if svc.feeds.getFeedByID(created.ID) != nil {
+		t.Fatal("expected feed to be deleted")
+	}
+
+	apiRequest(t, mux, http.MethodDelete, "/api/v1/feeds/"+created.ID, "", http.StatusNotFound, nil)
+}
+
+func TestAPI_Settings(t *testing.T) {
+	svc, _, mux := newAPITestService(t)
+
+	var settings apiSettings
+	apiRequest(t, mux, http.MethodGet, "/api/v1/settings", "", http.StatusOK, &settings)
+
+	if settings.UpdateSeconds != 900 {
+		t.Fatal("unexpected settings", settings)
+	}
+
+	apiRequest(t, mux, http.MethodPut, "/api/v1/settings", `{"update_seconds":1200,"archive_days":3}`, http.StatusOK, &settings)
+
+	if settings.UpdateSeconds != 1200 || svc.feeds.Config.ArchiveDays != 3 {
+		t.Fatal("expected settings to be updated", settings)
+	}
+
+	apiRequest(t, mux, http.MethodPut, "/api/v1/settings", `{"update_seconds":10}`, http.StatusBadRequest, nil)
+}
@@ -0,0 +1,168 @@
+package rssole
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/exp/slog"
+)
+
+// Ensure itemArchive implements ItemArchive.
+var _ ItemArchive = (*itemArchive)(nil)
+
+// archiveSeenResolution is how stale an item's LastSeen can get before it's
+// refreshed, so polling an unchanged feed doesn't rewrite the archive.
+const archiveSeenResolution = time.Hour
+
+type archivedItem struct {
+	Item     *gofeed.Item `json:"item"`
+	LastSeen time.Time    `json:"last_seen"`
+}
+
+// itemArchive is a json file backed store of every item seen per feed.
+// Items are kept for Retention after they were last seen upstream.
+// A zero Retention disables archiving.
+type itemArchive struct {
+	Filename  string
+	Retention time.Duration
+
+	store map[string]map[string]*archivedItem // feed id -> item id -> item
+	dirty bool                                // store has changed since the last Persist
+	mu    sync.Mutex
+}
+
+func (a *itemArchive) loadArchive() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	body, err := os.ReadFile(a.Filename)
+	if err != nil {
+		slog.Error("ReadFile failed", "filename", a.Filename, "error", err)
+	} else {
+		err = json.Unmarshal(body, &a.store)
+		if err != nil {
+			slog.Error("error unmarshal", "filename", a.Filename, "error", err)
+		}
+	}
+}
+
+func archiveRetention(days int) time.Duration {
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func (a *itemArchive) setRetention(d time.Duration) {
+	a.mu.Lock()
+	a.Retention = d
+	a.mu.Unlock()
+}
+
+func archiveItemID(item *gofeed.Item) string {
+	return (&wrappedItem{Item: item}).MarkReadID()
+}
+
+// Merge records the current upstream items for a feed and returns them
+// along with any previously seen items that are still within retention.
+func (a *itemArchive) Merge(feedID string, items []*gofeed.Item) []*gofeed.Item {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.Retention <= 0 {
+		return items
+	}
+
+	if a.store == nil {
+		a.store = map[string]map[string]*archivedItem{}
+	}
+
+	stored := a.store[feedID]
+	if stored == nil {
+		stored = map[string]*archivedItem{}
+		a.store[feedID] = stored
+	}
+
+	now := time.Now()
+	current := map[string]bool{}
+
+	for _, item := range items {
+		id := archiveItemID(item)
+		current[id] = true
+
+		ai, found := stored[id]
+		if !found || now.Sub(ai.LastSeen) > archiveSeenResolution {
+			stored[id] = &archivedItem{Item: item, LastSeen: now}
+			a.dirty = true
+
+			continue
+		}
+
+		ai.Item = item // keep the latest copy, but not worth a write on its own
+	}
+
+	merged := append([]*gofeed.Item{}, items...)
+
+	for id, ai := range stored {
+		if current[id] {
+			continue
+		}
+
+		if now.Sub(ai.LastSeen) > a.Retention {
+			delete(stored, id)
+
+			a.dirty = true
+
+			continue
+		}
+
+		merged = append(merged, ai.Item)
+	}
+
+	return merged
+}
+
+// prune drops the items of any feed that isn't in feeds.
+func (a *itemArchive) prune(feeds []*feed) {
+	live := map[string]bool{}
+	for _, f := range feeds {
+		live[f.ID()] = true
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for feedID := range a.store {
+		if !live[feedID] {
+			delete(a.store, feedID)
+
+			a.dirty = true
+		}
+	}
+}
+
+// Persist saves the archive to disk, if it has changed.
+func (a *itemArchive) Persist() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.Filename == "" || a.Retention <= 0 || !a.dirty {
+		return
+	}
+
+	jsonString, err := json.Marshal(a.store)
+	if err != nil {
+		slog.Error("error marshaling archive", "error", err)
+
+		return
+	}
+
+	err = os.WriteFile(a.Filename, jsonString, lutFilePerms)
+	if err != nil {
+		slog.Error("error writefile", "filename", a.Filename, "error", err)
+
+		return
+	}
+
+	a.dirty = false
+}
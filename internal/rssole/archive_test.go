@@ -0,0 +1,169 @@
+package rssole
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func TestArchiveMerge_Disabled(t *testing.T) {
+	a := itemArchive{}
+
+	items := []*gofeed.Item{{Link: "http://example.com/1"}}
+
+	merged := a.Merge("feed", items)
+	if len(merged) != 1 {
+		t.Fatal("expected items to pass through untouched, got", len(merged))
+	}
+
+	merged = a.Merge("feed", []*gofeed.Item{})
+	if len(merged) != 0 {
+		t.Fatal("expected nothing to be archived when disabled, got", len(merged))
+	}
+}
+
+func TestArchiveMerge_KeepsDroppedItems(t *testing.T) {
+	a := itemArchive{Retention: time.Hour}
+
+	a.Merge("feed", []*gofeed.Item{
+		{Link: "http://example.com/1"},
+		{Link: "http://example.com/2"},
+	})
+
+	// item 1 drops out of the upstream feed, item 3 appears
+	merged := a.Merge("feed", []*gofeed.Item{
+		{Link: "http://example.com/2"},
+		{Link: "http://example.com/3"},
+	})
+
+	if len(merged) != 3 {
+		t.Fatal("expected 3 items after merge, got", len(merged))
+	}
+
+	// current items come first, archived after
+	if merged[0].Link != "http://example.com/2" ||
+		merged[1].Link != "http://example.com/3" ||
+		merged[2].Link != "http://example.com/1" {
+		t.Error("unexpected merge order", merged[0].Link, merged[1].Link, merged[2].Link)
+	}
+
+	// other feeds are unaffected
+	if other := a.Merge("other", []*gofeed.Item{}); len(other) != 0 {
+		t.Error("expected no items for another feed, got", len(other))
+	}
+}
+
+func TestArchiveMerge_ExpiresOldItems(t *testing.T) {
+	a := itemArchive{
+		Retention: time.Hour,
+		store: map[string]map[string]*archivedItem{
+			"feed": {
+				"http://example.com/old": {
+					Item:     &gofeed.Item{Link: "http://example.com/old"},
+					LastSeen: time.Now().Add(-2 * time.Hour),
+				},
+				"http://example.com/new": {
+					Item:     &gofeed.Item{Link: "http://example.com/new"},
+					LastSeen: time.Now().Add(-30 * time.Minute),
+				},
+			},
+		},
+	}
+
+	merged := a.Merge("feed", []*gofeed.Item{})
+	if len(merged) != 1 || merged[0].Link != "http://example.com/new" {
+		t.Fatal("expected only the unexpired item, got", len(merged))
+	}
+
+	if _, found := a.store["feed"]["http://example.com/old"]; found {
+		t.Fatal("expected expired item to be removed from the store")
+	}
+}
+
+func TestArchivePersist(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "archive.json")
+
+	a1 := itemArchive{Filename: filename, Retention: time.Hour}
+	a1.Merge("feed", []*gofeed.Item{{Title: "Archived", Link: "http://example.com/1"}})
+	a1.Persist()
+
+	if _, err := os.Stat(filename); err != nil {
+		t.Fatal("expected archive file to be written", err)
+	}
+
+	a2 := itemArchive{Filename: filename, Retention: time.Hour}
+	a2.loadArchive()
+
+	merged := a2.Merge("feed", []*gofeed.Item{})
+	if len(merged) != 1 || merged[0].Title != "Archived" {
+		t.Fatal("expected archived item after reload, got", len(merged))
+	}
+}
+
+func TestArchivePersist_OnlyWhenChanged(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "archive.json")
+
+	a := itemArchive{Filename: filename, Retention: time.Hour}
+	items := []*gofeed.Item{{Link: "http://example.com/1"}}
+
+	a.Merge("feed", items)
+	a.Persist()
+
+	if err := os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	// seeing the same items again isn't worth a write
+	a.Merge("feed", items)
+	a.Persist()
+
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatal("expected the unchanged archive not to be written", err)
+	}
+
+	a.Merge("feed", append(items, &gofeed.Item{Link: "http://example.com/2"}))
+	a.Persist()
+
+	if _, err := os.Stat(filename); err != nil {
+		t.Fatal("expected a new item to be written", err)
+	}
+}
+
+func TestArchivePrune(t *testing.T) {
+	kept := &feed{URL: "http://example.com/kept"}
+	gone := &feed{URL: "http://example.com/gone"}
+
+	a := itemArchive{Retention: time.Hour}
+	a.Merge(kept.ID(), []*gofeed.Item{{Link: "http://example.com/1"}})
+	a.Merge(gone.ID(), []*gofeed.Item{{Link: "http://example.com/2"}})
+	a.dirty = false
+
+	a.prune([]*feed{kept})
+
+	if _, found := a.store[gone.ID()]; found {
+		t.Fatal("expected the removed feed's items to be pruned")
+	}
+
+	if _, found := a.store[kept.ID()]; !found {
+		t.Fatal("expected the remaining feed's items to be kept")
+	}
+
+	if !a.dirty {
+		t.Fatal("expected pruning to need a persist")
+	}
+}
+
+func TestDeleteFeed_PrunesArchive(t *testing.T) {
+	svc, f, _ := newAPITestService(t)
+	svc.archive.setRetention(time.Hour)
+	svc.archive.Merge(f.ID(), []*gofeed.Item{{Link: "http://example.com/1"}})
+
+	svc.deleteFeed(f.ID())
+
+	if _, found := svc.archive.store[f.ID()]; found {
+		t.Fatal("expected the deleted feed's items to be pruned")
+	}
+}
@@ -0,0 +1,311 @@
+package rssole
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/exp/slog"
+)
+
+// Authentication is off unless users are configured, in which case every
+// request (other than publicPaths) needs to come from one of them.
+
+const (
+	sessionCookieName = "rssole_session"
+	sessionLifetime   = 30 * 24 * time.Hour
+	sessionTokenBytes = 32
+)
+
+// publicPaths don't need authenticating. The sync APIs have their own auth,
+// and the PWA files are static.
+var publicPaths = []string{
+	"/libs/", "/login", "/fever/", greaderPrefix + "/", "/sw.js", "/manifest.webmanifest",
+}
+
+// AuthConfig holds the users allowed into the web UI and JSON API.
+type AuthConfig struct {
+	Users map[string]string `json:"users"` // user name -> bcrypt hash (see the hash-password command)
+}
+
+// Authenticator checks the credentials on a request, returning the user name.
+type Authenticator interface {
+	Authenticate(req *http.Request) (string, bool)
+}
+
+type userContextKey struct{}
+
+// userFromContext returns the authenticated user, or "" if there isn't one.
+func userFromContext(ctx context.Context) string {
+	user, _ := ctx.Value(userContextKey{}).(string)
+
+	return user
+}
+
+// HashPassword returns the bcrypt hash of password, for AuthConfig.Users.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password - %w", err)
+	}
+
+	return string(hash), nil
+}
+
+// passwordChecker checks passwords against the configured bcrypt hashes.
+// bcrypt is deliberately slow so successful checks are remembered, otherwise
+// every Basic auth request would pay for it.
+type passwordChecker struct {
+	config *ConfigSection
+
+	verified map[string]bool
+	mu       sync.Mutex
+}
+
+func (p *passwordChecker) Check(user, password string) bool {
+	if p.config.Auth == nil {
+		return false
+	}
+
+	hash, found := p.config.Auth.Users[user]
+	if !found {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(user + "\x00" + hash + "\x00" + password))
+	key := hex.EncodeToString(sum[:])
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.verified[key] {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false
+	}
+
+	if p.verified == nil {
+		p.verified = map[string]bool{}
+	}
+
+	p.verified[key] = true
+
+	return true
+}
+
+// basicAuthenticator is HTTP Basic auth, handy for scripts using the JSON API.
+type basicAuthenticator struct {
+	passwords *passwordChecker
+}
+
+func (b *basicAuthenticator) Authenticate(req *http.Request) (string, bool) {
+	user, password, ok := req.BasicAuth()
+	if !ok || !b.passwords.Check(user, password) {
+		return "", false
+	}
+
+	return user, true
+}
+
+type session struct {
+	User    string
+	Expires time.Time
+}
+
+// sessionStore holds the logged in sessions. Sessions are only kept in
+// memory, so a restart means logging in again.
+type sessionStore struct {
+	config *ConfigSection
+
+	sessions map[string]*session
+	mu       sync.Mutex
+}
+
+// Create starts a new session for the user, returning its token.
+func (s *sessionStore) Create(user string) (string, error) {
+	buf := make([]byte, sessionTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error creating session token - %w", err)
+	}
+
+	token := hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sessions == nil {
+		s.sessions = map[string]*session{}
+	}
+
+	// a good time to forget old sessions
+	for t, sess := range s.sessions {
+		if time.Now().After(sess.Expires) {
+			delete(s.sessions, t)
+		}
+	}
+
+	s.sessions[token] = &session{User: user, Expires: time.Now().Add(sessionLifetime)}
+
+	return token, nil
+}
+
+// Delete ends the session.
+func (s *sessionStore) Delete(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, token)
+}
+
+// Authenticate accepts requests with a session cookie for a user that still exists.
+func (s *sessionStore) Authenticate(req *http.Request) (string, bool) {
+	cookie, err := req.Cookie(sessionCookieName)
+	if err != nil {
+		return "", false
+	}
+
+	s.mu.Lock()
+	sess, found := s.sessions[cookie.Value]
+	s.mu.Unlock()
+
+	if !found || time.Now().After(sess.Expires) {
+		return "", false
+	}
+
+	if s.config.Auth == nil {
+		return "", false
+	}
+
+	if _, found := s.config.Auth.Users[sess.User]; !found {
+		return "", false
+	}
+
+	return sess.User, true
+}
+
+func (s *Service) authEnabled() bool {
+	return s.feeds.Config.Auth != nil && len(s.feeds.Config.Auth.Users) > 0
+}
+
+func isPublicPath(path string) bool {
+	for _, public := range publicPaths {
+		if path == public || (strings.HasSuffix(public, "/") && strings.HasPrefix(path, public)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requireAuth wraps the handler so only authenticated users get through,
+// the user is available via userFromContext.
+func (s *Service) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !s.authEnabled() || isPublicPath(req.URL.Path) {
+			next.ServeHTTP(w, req)
+
+			return
+		}
+
+		for _, a := range s.authenticators {
+			if user, ok := a.Authenticate(req); ok {
+				next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), userContextKey{}, user)))
+
+				return
+			}
+		}
+
+		switch {
+		case req.Header.Get("HX-Request") == "true":
+			// htmx won't follow a redirect for the whole page, so ask it to
+			w.Header().Set("HX-Redirect", "/login")
+			w.WriteHeader(http.StatusUnauthorized)
+		case req.Method == http.MethodGet && strings.Contains(req.Header.Get("Accept"), "text/html"):
+			http.Redirect(w, req, "/login", http.StatusSeeOther)
+		default:
+			w.Header().Set("WWW-Authenticate", `Basic realm="rssole"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		}
+	})
+}
+
+func (s *Service) loginGet(w http.ResponseWriter, req *http.Request) {
+	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
+
+	if err := s.templates["login.go.html"].Execute(w, map[string]any{
+		"Version": Version,
+	}); err != nil {
+		logger.Error("login.go.html", "error", err)
+	}
+}
+
+func (s *Service) loginPost(w http.ResponseWriter, req *http.Request) {
+	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
+
+	user := req.FormValue("username")
+
+	if !s.passwords.Check(user, req.FormValue("password")) {
+		logger.Info("failed login", "user", user)
+		w.WriteHeader(http.StatusUnauthorized)
+
+		if err := s.templates["login.go.html"].Execute(w, map[string]any{
+			"Version":  Version,
+			"Username": user,
+			"Error":    "Incorrect username or password.",
+		}); err != nil {
+			logger.Error("login.go.html", "error", err)
+		}
+
+		return
+	}
+
+	token, err := s.sessions.Create(user)
+	if err != nil {
+		logger.Error("sessions.Create", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(sessionLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   req.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, req, "/", http.StatusSeeOther)
+}
+
+func (s *Service) logout(w http.ResponseWriter, req *http.Request) {
+	if cookie, err := req.Cookie(sessionCookieName); err == nil {
+		s.sessions.Delete(cookie.Value)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	if req.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", "/login")
+
+		return
+	}
+
+	http.Redirect(w, req, "/login", http.StatusSeeOther)
+}
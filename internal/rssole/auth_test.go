@@ -0,0 +1,186 @@
+package rssole
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newAuthTestService(t *testing.T) (*Service, http.Handler) {
+	t.Helper()
+
+	svc := NewService()
+	if err := svc.loadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc.feeds.Config.Auth = &AuthConfig{Users: map[string]string{"alice": string(hash)}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("hello " + userFromContext(req.Context())))
+	})
+	mux.HandleFunc("GET /libs/", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("lib"))
+	})
+	mux.HandleFunc("GET /login", svc.loginGet)
+	mux.HandleFunc("POST /login", svc.loginPost)
+	mux.HandleFunc("POST /logout", svc.logout)
+
+	return svc, svc.requireAuth(mux)
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte("secret")) != nil {
+		t.Fatal("expected hash to match password")
+	}
+}
+
+func TestRequireAuth_Disabled(t *testing.T) {
+	svc, handler := newAuthTestService(t)
+	svc.feeds.Config.Auth = nil
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatal("expected no auth to be needed when no users are configured", rr.Code)
+	}
+}
+
+func TestRequireAuth_Unauthenticated(t *testing.T) {
+	_, handler := newAuthTestService(t)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/libs/htmx.min.js", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatal("expected /libs/ to be public", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/login", nil))
+
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `name="password"`) {
+		t.Fatal("expected the login page", rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "text/html")
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/login" {
+		t.Fatal("expected browsers to be redirected to login", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("HX-Request", "true")
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized || rr.Header().Get("HX-Redirect") != "/login" {
+		t.Fatal("expected htmx to be redirected to login", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rr.Code != http.StatusUnauthorized || rr.Header().Get("WWW-Authenticate") == "" {
+		t.Fatal("expected a basic auth challenge", rr.Code)
+	}
+}
+
+func TestRequireAuth_Basic(t *testing.T) {
+	_, handler := newAuthTestService(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetBasicAuth("alice", "secret")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || rr.Body.String() != "hello alice" {
+		t.Fatal("expected basic auth to work", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetBasicAuth("alice", "wrong")
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatal("expected a wrong password to fail", rr.Code)
+	}
+}
+
+func TestRequireAuth_Session(t *testing.T) {
+	_, handler := newAuthTestService(t)
+
+	form := url.Values{"username": {"alice"}, "password": {"wrong"}}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized || !strings.Contains(rr.Body.String(), "Incorrect username or password") {
+		t.Fatal("expected login to fail", rr.Code)
+	}
+
+	form.Set("password", "secret")
+	req = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther || len(rr.Result().Cookies()) != 1 {
+		t.Fatal("expected login to set a session cookie", rr.Code)
+	}
+
+	cookie := rr.Result().Cookies()[0]
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || rr.Body.String() != "hello alice" {
+		t.Fatal("expected the session cookie to work", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.AddCookie(cookie)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatal("expected the session to end on logout", rr.Code)
+	}
+}
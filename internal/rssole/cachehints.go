@@ -0,0 +1,155 @@
+package rssole
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/mmcdole/gofeed/rss"
+)
+
+// Publishers can say how often they want to be polled, in the response
+// headers (Cache-Control max-age, Expires, Retry-After) or in the feed
+// itself (RSS <ttl>, sy:updatePeriod and sy:updateFrequency). The next poll
+// is never sooner than they ask, up to maxHintDelay (so a misconfigured
+// server can't stop a feed updating).
+
+const maxHintDelay = 24 * time.Hour
+
+// ttlCustomKey is where ttlTranslator keeps the RSS <ttl>, which gofeed
+// doesn't otherwise carry over to the universal feed.
+const ttlCustomKey = "ttl"
+
+type ttlTranslator struct {
+	gofeed.DefaultRSSTranslator
+}
+
+func (t *ttlTranslator) Translate(feed interface{}) (*gofeed.Feed, error) {
+	result, err := t.DefaultRSSTranslator.Translate(feed)
+	if err != nil {
+		return nil, fmt.Errorf("rss translate - %w", err)
+	}
+
+	if rssFeed, ok := feed.(*rss.Feed); ok && rssFeed.TTL != "" {
+		if result.Custom == nil {
+			result.Custom = map[string]string{}
+		}
+
+		result.Custom[ttlCustomKey] = rssFeed.TTL
+	}
+
+	return result, nil
+}
+
+func newFeedParser() *gofeed.Parser {
+	fp := gofeed.NewParser()
+	fp.RSSTranslator = &ttlTranslator{}
+
+	return fp
+}
+
+// headerDelay is how long the response headers ask us to wait before
+// polling again, zero for no preference.
+func headerDelay(h http.Header, now time.Time) time.Duration {
+	delay := retryAfter(h, now)
+
+	if maxAge, found := cacheControlMaxAge(h.Get("Cache-Control")); found {
+		delay = max(delay, maxAge)
+	} else if expires, err := http.ParseTime(h.Get("Expires")); err == nil {
+		delay = max(delay, expires.Sub(now))
+	}
+
+	return min(delay, maxHintDelay)
+}
+
+// retryAfter parses Retry-After, which is either seconds or a date.
+func retryAfter(h http.Header, now time.Time) time.Duration {
+	value := strings.TrimSpace(h.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+
+	if when, err := http.ParseTime(value); err == nil {
+		return max(when.Sub(now), 0)
+	}
+
+	return 0
+}
+
+// cacheControlMaxAge returns the max-age directive, if there is one.
+// no-cache and no-store mean the publisher doesn't mind how often we ask.
+func cacheControlMaxAge(cacheControl string) (time.Duration, bool) {
+	maxAge, found := time.Duration(0), false
+
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+
+		switch strings.ToLower(name) {
+		case "no-cache", "no-store":
+			return 0, true
+		case "max-age":
+			if secs, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && secs > 0 {
+				maxAge, found = time.Duration(secs)*time.Second, true
+			}
+		}
+	}
+
+	return maxAge, found
+}
+
+// syndicationPeriods are the sy:updatePeriod values.
+var syndicationPeriods = map[string]time.Duration{
+	"hourly":  time.Hour,
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+	"yearly":  365 * 24 * time.Hour,
+}
+
+// feedDelay is how long the feed itself asks us to wait before polling
+// again, zero for no preference.
+func feedDelay(feed *gofeed.Feed) time.Duration {
+	delay := time.Duration(0)
+
+	if mins, err := strconv.Atoi(strings.TrimSpace(feed.Custom[ttlCustomKey])); err == nil && mins > 0 {
+		delay = time.Duration(mins) * time.Minute
+	}
+
+	if period, found := syndicationPeriods[strings.ToLower(syndicationValue(feed, "updatePeriod"))]; found {
+		frequency, err := strconv.Atoi(syndicationValue(feed, "updateFrequency"))
+		if err != nil || frequency < 1 {
+			frequency = 1
+		}
+
+		delay = max(delay, period/time.Duration(frequency))
+	}
+
+	return min(delay, maxHintDelay)
+}
+
+func syndicationValue(feed *gofeed.Feed, name string) string {
+	if values := feed.Extensions["sy"][name]; len(values) > 0 {
+		return strings.TrimSpace(values[0].Value)
+	}
+
+	return ""
+}
+
+// deferUntil stops the feed being polled again until the publisher's delay has passed.
+func (f *feed) deferUntil(delay time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.notBefore = time.Now().Add(delay)
+
+	if delay > 0 {
+		f.log.Info("Publisher asked for a delay before polling again", "delay", delay)
+	}
+}
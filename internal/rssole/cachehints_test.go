@@ -0,0 +1,98 @@
+package rssole
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHeaderDelay(t *testing.T) {
+	now := time.Now().Truncate(time.Second) // http dates only have second precision
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected time.Duration
+	}{
+		{"none", map[string]string{}, 0},
+		{"max-age", map[string]string{"Cache-Control": "public, max-age=1800"}, 30 * time.Minute},
+		{"no-cache", map[string]string{"Cache-Control": "no-cache, max-age=1800"}, 0},
+		{"expires", map[string]string{"Expires": now.Add(2 * time.Hour).UTC().Format(http.TimeFormat)}, 2 * time.Hour},
+		{"expired", map[string]string{"Expires": now.Add(-time.Hour).UTC().Format(http.TimeFormat)}, 0},
+		{"max-age beats expires", map[string]string{
+			"Cache-Control": "max-age=60",
+			"Expires":       now.Add(2 * time.Hour).UTC().Format(http.TimeFormat),
+		}, time.Minute},
+		{"retry-after seconds", map[string]string{"Retry-After": "120"}, 2 * time.Minute},
+		{"retry-after date", map[string]string{"Retry-After": now.Add(time.Hour).UTC().Format(http.TimeFormat)}, time.Hour},
+		{"retry-after junk", map[string]string{"Retry-After": "soon"}, 0},
+		{"capped", map[string]string{"Cache-Control": "max-age=31536000"}, maxHintDelay},
+	}
+
+	for _, tt := range tests {
+		h := http.Header{}
+		for k, v := range tt.headers {
+			h.Set(k, v)
+		}
+
+		if got := headerDelay(h, now); got != tt.expected {
+			t.Fatal(tt.name, "expected", tt.expected, "got", got)
+		}
+	}
+}
+
+func TestFeedDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		channel  string
+		expected time.Duration
+	}{
+		{"none", ``, 0},
+		{"ttl", `<ttl>90</ttl>`, 90 * time.Minute},
+		{"sy daily", `<sy:updatePeriod>daily</sy:updatePeriod>`, 24 * time.Hour},
+		{"sy frequency", `<sy:updatePeriod>daily</sy:updatePeriod><sy:updateFrequency>4</sy:updateFrequency>`, 6 * time.Hour},
+		{"largest wins", `<ttl>600</ttl><sy:updatePeriod>hourly</sy:updatePeriod>`, 10 * time.Hour},
+		{"capped", `<sy:updatePeriod>yearly</sy:updatePeriod>`, maxHintDelay},
+	}
+
+	for _, tt := range tests {
+		parsed, err := newFeedParser().ParseString(`<?xml version="1.0" encoding="UTF-8" ?>
+<rss version="2.0" xmlns:sy="http://purl.org/rss/1.0/modules/syndication/">
+<channel>
+  <title>Feed Title</title>
+  ` + tt.channel + `
+</channel>
+</rss>`)
+		if err != nil {
+			t.Fatal(tt.name, err)
+		}
+
+		if got := feedDelay(parsed); got != tt.expected {
+			t.Fatal(tt.name, "expected", tt.expected, "got", got)
+		}
+	}
+}
+
+func TestUpdate_RetryAfter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	f := &feed{URL: ts.URL, updatePeriod: time.Minute}
+	f.Init()
+
+	if err := f.Update(); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if wait := time.Until(f.notBefore); wait < 59*time.Minute {
+		t.Fatal("expected to wait for the Retry-After, got", wait)
+	}
+
+	if !f.tooSoon() {
+		t.Fatal("expected polling to be deferred")
+	}
+}
@@ -0,0 +1,159 @@
+package rssole
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/net/html"
+)
+
+// Discovery finds the feeds of a website, from the <link rel="alternate">
+// tags in its page and by trying the paths feeds commonly live at.
+
+const discoverMaxBody = 5 << 20
+
+var (
+	// discoverFeedTypes are the <link> types that point at feeds.
+	discoverFeedTypes = map[string]string{
+		"application/rss+xml":   "RSS",
+		"application/atom+xml":  "Atom",
+		"application/feed+json": "JSON Feed",
+	}
+
+	// discoverPaths are where feeds commonly live, relative to the site root.
+	discoverPaths = []string{"/feed", "/rss", "/rss.xml", "/atom.xml", "/feed.xml", "/index.xml", "/feed.json"}
+)
+
+type feedCandidate struct {
+	URL   string
+	Title string
+}
+
+// discoverGet fetches a url, returning the body and the final url (after redirects).
+func discoverGet(rawURL string) ([]byte, *url.URL, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot create new request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "rssole/"+Version)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, nil, gofeed.HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, discoverMaxBody))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read body: %w", err)
+	}
+
+	return body, resp.Request.URL, nil
+}
+
+func isFeed(body []byte) bool {
+	return gofeed.DetectFeedType(bytes.NewReader(body)) != gofeed.FeedTypeUnknown
+}
+
+// discoverFeeds returns the feeds found for the page, or true if the page
+// is a feed itself.
+func discoverFeeds(pageURL string) ([]*feedCandidate, bool, error) {
+	body, base, err := discoverGet(pageURL)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if isFeed(body) {
+		return nil, true, nil
+	}
+
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, false, fmt.Errorf("parse %s %w", pageURL, err)
+	}
+
+	candidates := []*feedCandidate{}
+	seen := map[string]bool{}
+
+	add := func(c *feedCandidate) {
+		if !seen[c.URL] {
+			seen[c.URL] = true
+			candidates = append(candidates, c)
+		}
+	}
+
+	for _, link := range queryAll(doc, `link[rel~="alternate"][href]`) {
+		kind, found := discoverFeedTypes[strings.ToLower(strings.TrimSpace(attrOr(link, "type", "")))]
+		if !found {
+			continue
+		}
+
+		href, err := base.Parse(attrOr(link, "href", ""))
+		if err != nil {
+			continue
+		}
+
+		add(&feedCandidate{URL: href.String(), Title: attrOr(link, "title", kind)})
+	}
+
+	for _, c := range probeFeedPaths(base) {
+		add(c)
+	}
+
+	return candidates, false, nil
+}
+
+// probeFeedPaths tries the common feed paths at the root of the site.
+func probeFeedPaths(base *url.URL) []*feedCandidate {
+	found := make([]*feedCandidate, len(discoverPaths))
+
+	var wg sync.WaitGroup
+
+	for idx, path := range discoverPaths {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			probeURL := base.ResolveReference(&url.URL{Path: path}).String()
+
+			body, _, err := discoverGet(probeURL)
+			if err != nil || !isFeed(body) {
+				return
+			}
+
+			title := probeURL
+			if parsed, err := gofeed.NewParser().Parse(bytes.NewReader(body)); err == nil && parsed.Title != "" {
+				title = parsed.Title
+			}
+
+			found[idx] = &feedCandidate{URL: probeURL, Title: title}
+		}()
+	}
+
+	wg.Wait()
+
+	candidates := []*feedCandidate{}
+
+	for _, c := range found {
+		if c != nil {
+			candidates = append(candidates, c)
+		}
+	}
+
+	return candidates
+}
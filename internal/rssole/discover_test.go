@@ -0,0 +1,161 @@
+package rssole
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const discoverTestRSS = `<?xml version="1.0" encoding="UTF-8" ?>
+<rss version="2.0">
+<channel>
+  <title>Probed Feed</title>
+  <link>http://example.com/</link>
+  <description>Found by probing</description>
+</channel>
+</rss>`
+
+func newDiscoverTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `<html><head>
+<link rel="alternate" type="application/rss+xml" title="Main Feed" href="/main.rss">
+<link rel="alternate" type="application/atom+xml" href="https://elsewhere.example.com/atom">
+<link rel="alternate" type="application/feed+json" title="JSON" href="feed.json">
+<link rel="alternate" hreflang="fr" href="/fr/">
+<link rel="stylesheet" type="text/css" href="/style.css">
+</head><body>Hello</body></html>`)
+	})
+	mux.HandleFunc("GET /rss.xml", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, discoverTestRSS)
+	})
+	mux.HandleFunc("GET /feed.json", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"version":"https://jsonfeed.org/version/1.1","title":"JSON","items":[]}`)
+	})
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestDiscoverFeeds(t *testing.T) {
+	ts := newDiscoverTestServer(t)
+
+	candidates, isFeed, err := discoverFeeds(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if isFeed {
+		t.Fatal("expected the page not to be a feed")
+	}
+
+	expected := []feedCandidate{
+		{URL: ts.URL + "/main.rss", Title: "Main Feed"},
+		{URL: "https://elsewhere.example.com/atom", Title: "Atom"},
+		{URL: ts.URL + "/feed.json", Title: "JSON"},
+		{URL: ts.URL + "/rss.xml", Title: "Probed Feed"},
+	}
+
+	if len(candidates) != len(expected) {
+		t.Fatal("unexpected candidates", candidates)
+	}
+
+	for idx, c := range candidates {
+		if *c != expected[idx] {
+			t.Fatal("expected", expected[idx], "got", *c)
+		}
+	}
+}
+
+func TestDiscoverFeeds_IsFeed(t *testing.T) {
+	ts := newDiscoverTestServer(t)
+
+	candidates, isFeed, err := discoverFeeds(ts.URL + "/rss.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !isFeed || len(candidates) != 0 {
+		t.Fatal("expected the url to be a feed")
+	}
+}
+
+func TestDiscoverFeeds_Error(t *testing.T) {
+	ts := newDiscoverTestServer(t)
+
+	if _, _, err := discoverFeeds(ts.URL + "/missing"); err == nil {
+		t.Fatal("expected an error for a missing page")
+	}
+}
+
+func TestCrudFeed_Post_OffersDiscoveredFeeds(t *testing.T) {
+	defer setUpTearDown(t)(t)
+
+	ts := newDiscoverTestServer(t)
+	currentNumFeeds := len(testService.feeds.All())
+
+	data := url.Values{}
+	data.Add("url", ts.URL+"/")
+	data.Add("category", "Found")
+
+	req, err := http.NewRequest(http.MethodPost, "/crudfeed", strings.NewReader(data.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(testService.crudfeedPost).ServeHTTP(rr, req)
+
+	if len(testService.feeds.All()) != currentNumFeeds {
+		t.Fatal("expected no feed to be added until one is picked")
+	}
+
+	for _, expected := range []string{"Main Feed", ts.URL + "/rss.xml", `name="category" value="Found"`, `name="discovered"`} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Fatal("expected", expected, "in", rr.Body.String())
+		}
+	}
+}
+
+func TestCrudFeed_Post_NothingDiscovered(t *testing.T) {
+	defer setUpTearDown(t)(t)
+
+	// every page is html, so there are no feeds to find
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `<html><body>Nothing here</body></html>`)
+	}))
+	defer ts.Close()
+
+	currentNumFeeds := len(testService.feeds.All())
+
+	data := url.Values{}
+	data.Add("url", ts.URL+"/nofeeds")
+
+	req, err := http.NewRequest(http.MethodPost, "/crudfeed", strings.NewReader(data.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(testService.crudfeedPost).ServeHTTP(rr, req)
+
+	if len(testService.feeds.All()) != currentNumFeeds {
+		t.Fatal("expected no feed to be added")
+	}
+
+	// straight on to the preview, which explains the page isn't a feed
+	if !strings.Contains(rr.Body.String(), "Save anyway") {
+		t.Fatal("expected a failed preview, got", rr.Body.String())
+	}
+}
@@ -6,18 +6,22 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
-	"time"
 
 	"golang.org/x/exp/slog"
 )
 
 const MinUpdateSeconds = 900
 
+// starredSelected is the feed list selection for the virtual starred feed.
+const starredSelected = "_starred"
+
 func (s *Service) index(w http.ResponseWriter, req *http.Request) {
 	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
 
 	if err := s.templates["base.go.html"].Execute(w, map[string]any{
 		"Version": Version,
+		"User":    userFromContext(req.Context()),
+		"EventID": s.eventID(),
 	}); err != nil {
 		logger.Error("base.go.html", "error", err)
 	}
@@ -40,45 +44,17 @@ func (s *Service) feedlistCommon(w http.ResponseWriter, selected string, logger
 	if err := s.templates["feedlist.go.html"].Execute(w, map[string]any{
 		"Selected": selected,
 		"Feeds":    s.feeds,
+		"Starred":  s.stars.Count(),
 	}); err != nil {
 		logger.Error("feedlist.go.html", "error", err)
 	}
 }
 
-func (s *Service) feedsNotModified(req *http.Request) bool {
-	// make precision equal for test
-	lastmod, _ := http.ParseTime(s.getLastmodified().Format(http.TimeFormat))
-
-	imsRaw := req.Header.Get("if-modified-since")
-	if imsRaw != "" {
-		// has any feed (or mark as read) been modified since last time?
-		ims, err := http.ParseTime(req.Header.Get("if-modified-since"))
-		if err == nil {
-			if ims.After(lastmod) ||
-				ims.Equal(lastmod) {
-				return true
-			}
-		}
-	}
-
-	return false
-}
-
 func (s *Service) feedlist(w http.ResponseWriter, req *http.Request) {
 	s.recordActivity()
 
 	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
 
-	// To greatly reduce the bandwidth from polling we use Last-Modified/If-Modified-Since
-	// which is respected by htmx.
-	/* FIXME this doesn't work in the wild for reasons not identified
-	if s.feedsNotModified(req) {
-		w.WriteHeader(http.StatusNotModified)
-
-		return
-	}
-	*/
-
 	selected := req.URL.Query().Get("selected")
 	s.feedlistCommon(w, selected, logger)
 }
@@ -101,18 +77,10 @@ func (s *Service) items(w http.ResponseWriter, req *http.Request) {
 		}
 
 		if f := s.feeds.list.FindByURL(feedURL); f != nil && f.feed != nil {
-			f.mu.Lock()
-			for _, i := range f.Items() {
-				if markRead[i.MarkReadID()] {
-					logger.Info("marking read", "MarkReadID", i.MarkReadID())
-					i.IsUnread = false
-					s.readLut.MarkRead(i.MarkReadID())
-				}
-			}
-			f.mu.Unlock()
+			s.markRead(f, func(i *wrappedItem) bool {
+				return markRead[i.MarkReadID()]
+			})
 		}
-
-		s.readLut.Persist()
 	}
 
 	if f := s.feeds.list.FindByURL(feedURL); f != nil {
@@ -133,26 +101,113 @@ func (s *Service) item(w http.ResponseWriter, req *http.Request) {
 	feedURL := req.URL.Query().Get("url")
 	id := req.URL.Query().Get("id")
 
-	f := s.feeds.list.FindByURL(feedURL)
-	if f == nil || f.feed == nil {
-		return
-	}
+	if f := s.feeds.list.FindByURL(feedURL); f != nil && f.feed != nil {
+		f.mu.Lock()
+		defer f.mu.Unlock()
 
-	f.mu.Lock()
-	defer f.mu.Unlock()
+		for _, item := range f.Items() {
+			if item.ID() == id {
+				s.renderItem(w, item)
 
-	for _, item := range f.Items() {
-		if item.ID() == id {
-			item.IsUnread = false
-			if err := s.templates["item.go.html"].Execute(w, item); err != nil {
-				slog.Error("item.go.html", "error", err)
+				return
 			}
+		}
+	}
 
-			s.readLut.MarkRead(item.MarkReadID())
-			s.readLut.Persist()
+	// Not in the feed (any more), but it may be a starred snapshot.
+	if item := s.stars.Find(id, s.feeds.list); item != nil {
+		s.renderItem(w, item)
+	}
+}
+
+func (s *Service) renderItem(w http.ResponseWriter, item *wrappedItem) {
+	item.IsUnread = false
+	if err := s.templates["item.go.html"].Execute(w, item); err != nil {
+		slog.Error("item.go.html", "error", err)
+	}
+
+	s.readLut.MarkRead(item.MarkReadID())
+	s.readLut.Persist()
+}
+
+func (s *Service) starred(w http.ResponseWriter, req *http.Request) {
+	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
+
+	items := s.stars.All(s.feeds.list)
+	for _, item := range items {
+		item.IsUnread = s.readLut.IsUnread(item.MarkReadID())
+	}
+
+	if err := s.templates["starred.go.html"].Execute(w, items); err != nil {
+		logger.Error("starred.go.html", "error", err)
+	}
+
+	// update feed list (oob)
+	s.feedlistCommon(w, starredSelected, logger)
+}
+
+func (s *Service) star(w http.ResponseWriter, req *http.Request) {
+	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
+
+	feedURL := req.URL.Query().Get("url")
+	id := req.URL.Query().Get("id")
 
-			break
+	var item *wrappedItem
+
+	if f := s.feeds.list.FindByURL(feedURL); f != nil {
+		f.mu.RLock()
+
+		for _, i := range f.Items() {
+			if i.ID() == id {
+				item = i
+
+				break
+			}
 		}
+
+		f.mu.RUnlock()
+	}
+
+	if item == nil {
+		item = s.stars.Find(id, s.feeds.list)
+	}
+
+	if item == nil {
+		logger.Error("item to star not found", "url", feedURL, "id", id)
+
+		return
+	}
+
+	starred := s.stars.Toggle(item)
+	s.stars.Persist()
+
+	logger.Info("toggled star", "id", id, "starred", starred)
+
+	if err := s.templates["star.go.html"].Execute(w, map[string]any{
+		"Item":    item,
+		"Starred": s.stars.Count(),
+	}); err != nil {
+		logger.Error("star.go.html", "error", err)
+	}
+}
+
+func (s *Service) search(w http.ResponseWriter, req *http.Request) {
+	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
+
+	query := strings.TrimSpace(req.URL.Query().Get("q"))
+	results := s.feeds.search(searchTerms(query), s.readLut)
+
+	numItems := 0
+	for _, r := range results {
+		numItems += len(r.Items)
+	}
+
+	if err := s.templates["search.go.html"].Execute(w, map[string]any{
+		"Query":    query,
+		"Results":  results,
+		"NumItems": numItems,
+	}); err != nil {
+		logger.Error("search.go.html", "error", err)
 	}
 }
 
@@ -171,6 +226,58 @@ func (s *Service) crudfeedGet(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// scrapeFromForm reads the scrape fields of the feed form, nil if there are none.
+func scrapeFromForm(req *http.Request) *scrape {
+	scrapeURLs := req.FormValue("scrape.urls")
+	scrapeItem := req.FormValue("scrape.item")
+	scrapeTitle := req.FormValue("scrape.title")
+	scrapeLink := req.FormValue("scrape.link")
+	jsonConf := jsonScrapeFromForm(req)
+
+	if scrapeURLs == "" && scrapeItem == "" && scrapeTitle == "" && scrapeLink == "" && jsonConf == nil {
+		return nil
+	}
+
+	maxPages, _ := strconv.Atoi(req.FormValue("scrape.max_pages")) // blank is the default
+
+	return &scrape{
+		URLs:            strings.Split(strings.ReplaceAll(strings.TrimSpace(scrapeURLs), "\r", ""), "\n"), // browsers send CRLF
+		Item:            scrapeItem,
+		Title:           scrapeTitle,
+		Link:            scrapeLink,
+		Description:     req.FormValue("scrape.description"),
+		DescriptionAttr: req.FormValue("scrape.description_attr"),
+		Date:            req.FormValue("scrape.date"),
+		DateAttr:        req.FormValue("scrape.date_attr"),
+		DateLayout:      req.FormValue("scrape.date_layout"),
+		Image:           req.FormValue("scrape.image"),
+		ImageAttr:       req.FormValue("scrape.image_attr"),
+		Author:          req.FormValue("scrape.author"),
+		AuthorAttr:      req.FormValue("scrape.author_attr"),
+		Next:            req.FormValue("scrape.next"),
+		MaxPages:        maxPages,
+		JSON:            jsonConf,
+	}
+}
+
+// jsonScrapeFromForm reads the json scrape fields of the feed form, nil if there are none.
+func jsonScrapeFromForm(req *http.Request) *jsonScrape {
+	conf := &jsonScrape{
+		Items:      req.FormValue("scrape.json.items"),
+		Title:      req.FormValue("scrape.json.title"),
+		Link:       req.FormValue("scrape.json.link"),
+		Date:       req.FormValue("scrape.json.date"),
+		DateLayout: req.FormValue("scrape.json.date_layout"),
+		Content:    req.FormValue("scrape.json.content"),
+	}
+
+	if *conf == (jsonScrape{}) {
+		return nil
+	}
+
+	return conf
+}
+
 func (s *Service) crudfeedPost(w http.ResponseWriter, req *http.Request) {
 	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
 
@@ -183,38 +290,41 @@ func (s *Service) crudfeedPost(w http.ResponseWriter, req *http.Request) {
 	feedurl := req.FormValue("url")
 	name := req.FormValue("name")
 	category := req.FormValue("category")
+	updateSeconds, _ := strconv.Atoi(req.FormValue("update_seconds")) // blank (or junk) is the global setting
 
-	scrapeURLs := req.FormValue("scrape.urls")
-	scrapeItem := req.FormValue("scrape.item")
-	scrapeTitle := req.FormValue("scrape.title")
-	scrapeLink := req.FormValue("scrape.link")
+	scr := scrapeFromForm(req)
+
+	formFeed := &feed{
+		URL:           feedurl,
+		Name:          name,
+		Category:      category,
+		Scrape:        scr,
+		UpdateSeconds: updateSeconds,
+		FullContent:   req.FormValue("full_content") != "",
+	}
 
-	var scr *scrape
-	if scrapeURLs != "" || scrapeItem != "" || scrapeTitle != "" || scrapeLink != "" {
-		scr = &scrape{
-			URLs:  strings.Split(strings.TrimSpace(scrapeURLs), "\n"),
-			Item:  scrapeItem,
-			Title: scrapeTitle,
-			Link:  scrapeLink,
+	// nothing is saved until the user has seen a dry run of the feed,
+	// and never with an update_seconds the API would refuse
+	invalid := checkUpdateSeconds(formFeed.UpdateSeconds) != nil
+	if req.FormValue("delete") == "" && (req.FormValue("confirmed") == "" || invalid) {
+		if !invalid && id == "" && scr == nil && req.FormValue("discovered") == "" && s.offerDiscoveredFeeds(w, formFeed, logger) {
+			return
 		}
+
+		s.previewFeed(w, req.PostForm, formFeed, logger)
+
+		return
 	}
 
 	if id != "" { // edit or delete
 		del := req.FormValue("delete")
 		if del != "" {
-			s.feeds.delFeed(id)
+			s.deleteFeed(id)
 			fmt.Fprint(w, `Deleted.`)
 			s.feedlistCommon(w, "_", logger)
 		} else {
 			// update
-			f := s.feeds.getFeedByID(id)
-			if f != nil {
-				f.mu.Lock()
-				f.URL = feedurl
-				f.Name = name
-				f.Category = category
-				f.Scrape = scr
-				f.mu.Unlock()
+			if f := s.updateFeed(id, formFeed); f != nil {
 				s.feedlistCommon(w, f.Title(), logger)
 				fmt.Fprintf(w, `<div hx-get="/items?url=%s" hx-trigger="load" hx-target="#items"></div>`, url.QueryEscape(f.URL))
 			} else {
@@ -222,16 +332,9 @@ func (s *Service) crudfeedPost(w http.ResponseWriter, req *http.Request) {
 			}
 		}
 	} else { // add
-		feed := &feed{
-			URL:      feedurl,
-			Name:     name,
-			Category: category,
-			Scrape:   scr,
-		}
-		feed.Init()
-		s.feeds.addFeed(feed, s.readLut, s)
+		s.addFeed(formFeed)
 
-		fmt.Fprintf(w, `<div hx-get="/items?url=%s" hx-trigger="load" hx-target="#items"></div>`, url.QueryEscape(feed.URL))
+		fmt.Fprintf(w, `<div hx-get="/items?url=%s" hx-trigger="load" hx-target="#items"></div>`, url.QueryEscape(formFeed.URL))
 	}
 	// something may have changed, so save it.
 	if err := s.feeds.saveFeedsFile(); err != nil {
@@ -239,6 +342,110 @@ func (s *Service) crudfeedPost(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// offerDiscoveredFeeds lets the user pick from the feeds found on a website,
+// returning false if the url is already a feed (or nothing was found).
+func (s *Service) offerDiscoveredFeeds(w http.ResponseWriter, formFeed *feed, logger *slog.Logger) bool {
+	candidates, isFeed, err := discoverFeeds(formFeed.URL)
+	if err != nil {
+		logger.Info("feed discovery failed", "url", formFeed.URL, "error", err)
+
+		return false
+	}
+
+	if isFeed || len(candidates) == 0 {
+		return false
+	}
+
+	if err := s.templates["discover.go.html"].Execute(w, map[string]any{
+		"URL":           formFeed.URL,
+		"Name":          formFeed.Name,
+		"Category":      formFeed.Category,
+		"UpdateSeconds": formFeed.UpdateSeconds,
+		"FullContent":   formFeed.FullContent,
+		"Candidates":    candidates,
+	}); err != nil {
+		logger.Error("discover.go.html", "error", err)
+	}
+
+	return true
+}
+
+// previewFeed shows a dry run of the feed (or why it failed), with a
+// button to save it that resubmits the form.
+func (s *Service) previewFeed(w http.ResponseWriter, form url.Values, formFeed *feed, logger *slog.Logger) {
+	data := map[string]any{
+		"Form": form,
+		"ID":   form.Get("id"),
+	}
+
+	if err := checkUpdateSeconds(formFeed.UpdateSeconds); err != nil {
+		data["Error"] = err.Error()
+		data["Invalid"] = true // can't be saved anyway
+	} else if parsed, err := dryRun(formFeed.URL, formFeed.Scrape); err != nil {
+		data["Error"] = err.Error()
+	} else {
+		data["Title"] = parsed.Title
+		data["Count"] = len(parsed.Items)
+		data["Items"] = parsed.Items[:min(len(parsed.Items), previewMaxItems)]
+	}
+
+	if err := s.templates["preview.go.html"].Execute(w, data); err != nil {
+		logger.Error("preview.go.html", "error", err)
+	}
+}
+
+func (s *Service) opmlGet(w http.ResponseWriter, req *http.Request) {
+	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
+
+	w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="rssole.opml"`)
+
+	if err := s.feeds.writeOPML(w); err != nil {
+		logger.Error("writeOPML", "error", err)
+	}
+}
+
+const maxOPMLUploadBytes = 10 << 20 // 10MB
+
+func (s *Service) opmlPost(w http.ResponseWriter, req *http.Request) {
+	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
+
+	if err := req.ParseMultipartForm(maxOPMLUploadBytes); err != nil {
+		logger.Error("ParseMultipartForm", "error", err)
+		fmt.Fprint(w, `Unable to read upload.`)
+
+		return
+	}
+
+	file, _, err := req.FormFile("opml")
+	if err != nil {
+		logger.Error("FormFile", "error", err)
+		fmt.Fprint(w, `No OPML file supplied.`)
+
+		return
+	}
+	defer file.Close()
+
+	newFeeds, err := s.feeds.readOPML(file)
+	if err != nil {
+		logger.Error("readOPML", "error", err)
+		fmt.Fprint(w, `Unable to parse OPML file.`)
+
+		return
+	}
+
+	for _, fd := range newFeeds {
+		s.addFeed(fd)
+	}
+
+	fmt.Fprintf(w, `Imported %d feeds.`, len(newFeeds))
+	s.feedlistCommon(w, "_", logger)
+
+	if err := s.feeds.saveFeedsFile(); err != nil {
+		logger.Error("saveFeedsFile", "error", err)
+	}
+}
+
 func (s *Service) settingsGet(w http.ResponseWriter, req *http.Request) {
 	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
 
@@ -264,14 +471,21 @@ func (s *Service) settingsPost(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if updateSeconds < MinUpdateSeconds {
-		logger.Error("Error, update_seconds is below 900")
+	archiveDays := s.feeds.Config.ArchiveDays
 
-		return
+	if archiveDaysRaw := req.FormValue("archive_days"); archiveDaysRaw != "" {
+		archiveDays, err = strconv.Atoi(archiveDaysRaw)
+		if err != nil {
+			logger.Error("Cannot parse archive_days", "error", err)
+
+			return
+		}
 	}
 
-	if updateSeconds != s.feeds.Config.UpdateSeconds {
-		s.feeds.ChangeTickedUpdate(time.Duration(updateSeconds) * time.Second)
+	if err := s.changeSettings(updateSeconds, archiveDays); err != nil {
+		logger.Error("Cannot change settings", "error", err)
+
+		return
 	}
 
 	// something may have changed, so save it.
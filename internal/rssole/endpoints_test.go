@@ -1,7 +1,9 @@
 package rssole
 
 import (
+	"bytes"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -85,6 +87,11 @@ func setUpTearDown(_ *testing.T) func(t *testing.T) {
 		Filename: file.Name(),
 	}
 
+	// and likewise for the starred items
+	testService.stars = &starStore{
+		Filename: file.Name() + ".starred",
+	}
+
 	return func(_ *testing.T) {
 		os.RemoveAll(readCacheDir)
 	}
@@ -156,38 +163,6 @@ func TestFeedlist(t *testing.T) {
 	}
 }
 
-/* FIXME: for some reason the not modified doesn't work as expected in the browser. I cannot pinpoint why.
-func TestFeedlist_NotModified(t *testing.T) {
-	defer setUpTearDown(t)(t)
-
-	req, err := http.NewRequest(http.MethodGet, "/feeds", nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	req.Header.Add("If-Modified-Since", time.Now().Format(http.TimeFormat))
-
-	yesterday := time.Now().Add(-time.Hour * 24)
-
-	testService.muLastmodified.Lock()
-	testService.lastmodified = yesterday
-	testService.muLastmodified.Unlock()
-
-	// We create a ResponseRecorder (which satisfies http.ResponseWriter) to record the response.
-	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(testService.feedlist)
-
-	// Our handlers satisfy http.Handler, so we can call their ServeHTTP method
-	// directly and pass in our Request and ResponseRecorder.
-	handler.ServeHTTP(rr, req)
-
-	if status := rr.Code; status != http.StatusNotModified {
-		t.Errorf("handler returned wrong status code: got %v want %v",
-			status, http.StatusNotModified)
-	}
-}
-*/
-
 func TestFeedlist_Modified(t *testing.T) {
 	defer setUpTearDown(t)(t)
 
@@ -358,6 +333,7 @@ func TestCrudFeed_Post_AddRssFeed(t *testing.T) {
 
 	data := url.Values{}
 	data.Add("url", "http://example.com/added_feed_url")
+	data.Add("confirmed", "true") // skip the preview
 	data.Add("name", "Feed Nickname")
 	data.Add("category", "Super Category")
 
@@ -398,6 +374,39 @@ func TestCrudFeed_Post_AddRssFeed(t *testing.T) {
 	}
 }
 
+func TestCrudFeed_Post_UpdateSecondsTooLow(t *testing.T) {
+	defer setUpTearDown(t)(t)
+
+	currentNumFeeds := len(testService.feeds.All())
+
+	data := url.Values{}
+	data.Add("url", "http://example.com/too_often")
+	data.Add("update_seconds", "60")
+	data.Add("confirmed", "true") // even a crafted save is refused
+
+	req, err := http.NewRequest(http.MethodPost, "/crudfeed", strings.NewReader(data.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(testService.crudfeedPost).ServeHTTP(rr, req)
+
+	if !strings.Contains(rr.Body.String(), ErrUpdateSecondsTooLow.Error()) {
+		t.Fatal("expected the preview to show the error, got", rr.Body.String())
+	}
+
+	if strings.Contains(rr.Body.String(), "Save") {
+		t.Fatal("expected no way to save the feed, got", rr.Body.String())
+	}
+
+	if len(testService.feeds.All()) != currentNumFeeds {
+		t.Fatal("expected the feed not to be added")
+	}
+}
+
 func TestCrudFeed_Post_AddRssFeed_WithScrape(t *testing.T) {
 	defer setUpTearDown(t)(t)
 
@@ -406,6 +415,7 @@ func TestCrudFeed_Post_AddRssFeed_WithScrape(t *testing.T) {
 
 	data := url.Values{}
 	data.Add("url", "http://example.com/added_feed_url")
+	data.Add("confirmed", "true") // skip the preview
 	data.Add("name", "Feed Nickname")
 	data.Add("category", "Super Category")
 
@@ -511,6 +521,7 @@ func TestCrudFeed_Post_UpdateRssFeed_WithScrape(t *testing.T) {
 	data := url.Values{}
 	data.Add("id", testService.feeds.All()[0].ID()) // replace whatever's there
 	data.Add("url", "http://example.com/added_feed_url")
+	data.Add("confirmed", "true") // skip the preview
 	data.Add("name", "Feed Nickname")
 	data.Add("category", "Super Category")
 
@@ -634,3 +645,251 @@ func TestSettings_Post(t *testing.T) {
 		}
 	}
 }
+
+func TestOPML_Get(t *testing.T) {
+	defer setUpTearDown(t)(t)
+
+	exported := &feed{URL: "http://example.com/exported_feed", Name: "Exported Feed!"}
+	testService.feeds.list.Add(exported)
+
+	defer testService.feeds.list.Remove(exported.ID())
+
+	req, err := http.NewRequest(http.MethodGet, "/opml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(testService.opmlGet)
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusOK)
+	}
+
+	for _, expectedToFind := range []string{
+		"<opml",
+		"http://example.com/exported_feed",
+		"Exported Feed!",
+	} {
+		if !strings.Contains(rr.Body.String(), expectedToFind) {
+			t.Errorf("handler returned page without expected content: got %v could not find '%v'",
+				rr.Body.String(), expectedToFind)
+		}
+	}
+}
+
+func TestOPML_Post(t *testing.T) {
+	defer setUpTearDown(t)(t)
+
+	// saving needs somewhere to go
+	testService.feeds.filename = readCacheDir + "/rssole.json"
+
+	currentNumFeeds := len(testService.feeds.All())
+	existingURL := testService.feeds.All()[0].URL
+
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+
+	fw, err := mw.CreateFormFile("opml", "feeds.opml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = fw.Write([]byte(`<opml version="2.0"><body>
+<outline text="Imported">
+  <outline text="Imported Feed" xmlUrl="http://example.com/imported_feed"/>
+  <outline text="Existing Feed" xmlUrl="` + existingURL + `"/>
+</outline>
+</body></opml>`))
+	mw.Close()
+
+	req, err := http.NewRequest(http.MethodPost, "/opml", &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Add("Content-Type", mw.FormDataContentType())
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(testService.opmlPost)
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusOK)
+	}
+
+	if !strings.Contains(rr.Body.String(), "Imported 1 feeds.") {
+		t.Error("expected import count in response, got:", rr.Body.String())
+	}
+
+	// only the new feed should be added, the existing one is skipped
+	if len(testService.feeds.All()) != currentNumFeeds+1 {
+		t.Fatalf("expected one more feed, but got: %d", len(testService.feeds.All()))
+	}
+
+	newFeed := testService.feeds.All()[currentNumFeeds]
+	defer testService.feeds.delFeed(newFeed.ID())
+
+	if newFeed.URL != "http://example.com/imported_feed" ||
+		newFeed.Name != "Imported Feed" ||
+		newFeed.Category != "Imported" {
+		t.Error("unexpected imported feed", newFeed.URL, newFeed.Name, newFeed.Category)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	defer setUpTearDown(t)(t)
+
+	searchable := &feed{URL: "http://example.com/searchable_feed", Name: "Searchable Feed!"}
+	searchable.Init()
+
+	searchableItems := []*wrappedItem{
+		{
+			IsUnread: true,
+			Feed:     searchable,
+			Item: &gofeed.Item{
+				Title: "Findable Story Title",
+				Link:  "http://example.com/findable",
+			},
+		},
+	}
+	searchable.wrappedItems.Store(&searchableItems)
+
+	testService.feeds.list.Add(searchable)
+
+	defer testService.feeds.list.Remove(searchable.ID())
+
+	req, err := http.NewRequest(http.MethodGet, "/search?q=findable+story", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(testService.search)
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusOK)
+	}
+
+	for _, expectedToFind := range []string{
+		"1 results for",
+		"Searchable Feed!",
+		"Findable Story Title",
+		"http://example.com/findable",
+	} {
+		if !strings.Contains(rr.Body.String(), expectedToFind) {
+			t.Errorf("handler returned page without expected content: got %v could not find '%v'",
+				rr.Body.String(), expectedToFind)
+		}
+	}
+}
+
+func TestStar(t *testing.T) {
+	defer setUpTearDown(t)(t)
+
+	starrable := &feed{URL: "http://example.com/starrable_feed", Name: "Starrable Feed!"}
+	starrable.Init()
+
+	starrableItem := &wrappedItem{
+		IsUnread: true,
+		Feed:     starrable,
+		Item: &gofeed.Item{
+			Title: "Starrable Story Title",
+			Link:  "http://example.com/starrable",
+		},
+	}
+	starrableItems := []*wrappedItem{starrableItem}
+	starrable.wrappedItems.Store(&starrableItems)
+
+	testService.feeds.list.Add(starrable)
+
+	defer testService.feeds.list.Remove(starrable.ID())
+
+	req, err := http.NewRequest(http.MethodPost, "/star?url="+url.QueryEscape(starrable.URL)+"&id="+starrableItem.ID(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(testService.star)
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusOK)
+	}
+
+	if !testService.stars.IsStarred(starrableItem.ID()) {
+		t.Fatal("expected item to be starred")
+	}
+
+	for _, expectedToFind := range []string{
+		"bi-star-fill",
+		`id="starredcount"`,
+	} {
+		if !strings.Contains(rr.Body.String(), expectedToFind) {
+			t.Errorf("handler returned page without expected content: got %v could not find '%v'",
+				rr.Body.String(), expectedToFind)
+		}
+	}
+
+	// now the item vanishes upstream, but should still be in the starred view
+	noItems := []*wrappedItem{}
+	starrable.wrappedItems.Store(&noItems)
+
+	req, err = http.NewRequest(http.MethodGet, "/starred", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr = httptest.NewRecorder()
+	handler = http.HandlerFunc(testService.starred)
+	handler.ServeHTTP(rr, req)
+
+	for _, expectedToFind := range []string{
+		"Starred",
+		"Starrable Story Title",
+		"http://example.com/starrable",
+	} {
+		if !strings.Contains(rr.Body.String(), expectedToFind) {
+			t.Errorf("handler returned page without expected content: got %v could not find '%v'",
+				rr.Body.String(), expectedToFind)
+		}
+	}
+
+	// and the item body should come from the snapshot
+	req, err = http.NewRequest(http.MethodGet, "/item?id="+starrableItem.ID()+"&url="+url.QueryEscape(starrable.URL), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr = httptest.NewRecorder()
+	handler = http.HandlerFunc(testService.item)
+	handler.ServeHTTP(rr, req)
+
+	if !strings.Contains(rr.Body.String(), "Starrable Story Title") {
+		t.Errorf("expected starred snapshot to be rendered, got %v", rr.Body.String())
+	}
+}
+
+func TestSearch_EscapesQuery(t *testing.T) {
+	defer setUpTearDown(t)(t)
+
+	req, err := http.NewRequest(http.MethodGet, "/search?q=%3Cscript%3Ealert(1)%3C%2Fscript%3E", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(testService.search).ServeHTTP(rr, req)
+
+	if strings.Contains(rr.Body.String(), "<script>") || !strings.Contains(rr.Body.String(), "&lt;script&gt;") {
+		t.Fatal("expected the query to be escaped", rr.Body.String())
+	}
+}
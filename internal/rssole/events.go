@@ -0,0 +1,219 @@
+package rssole
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// /events is a Server-Sent Events stream telling the UI when the feed list
+// has changed (via UpdateLastModified), so it doesn't have to poll.
+
+const (
+	// Marking a page of items read changes things once per item, wait for
+	// them all rather than sending an event for each.
+	eventsDebounce = 500 * time.Millisecond
+	// Stops proxies closing the connection when nothing is happening.
+	eventsKeepAlive = 30 * time.Second
+)
+
+// eventBroker fans change notifications out to the connected streams.
+type eventBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subscribers: map[chan struct{}]struct{}{}}
+}
+
+func (b *eventBroker) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch
+}
+
+func (b *eventBroker) unsubscribe(ch chan struct{}) {
+	b.mu.Lock()
+	delete(b.subscribers, ch)
+	b.mu.Unlock()
+}
+
+// publish never blocks, a subscriber that hasn't caught up yet already has
+// a notification waiting.
+func (b *eventBroker) publish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// connected returns how many streams are open.
+func (b *eventBroker) connected() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.subscribers)
+}
+
+type feedUnread struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Category string `json:"category"`
+	Unread   int    `json:"unread"`
+	Error    bool   `json:"error"` // the last update failed
+}
+
+type feedsEvent struct {
+	Changed []string     `json:"changed"` // ids of the feeds that differ from the last event
+	Feeds   []feedUnread `json:"feeds"`
+	Starred int          `json:"starred"`
+}
+
+// feedsEvent builds the next event, last is what this stream was sent
+// before and is updated.
+func (s *Service) feedsEvent(last map[string]feedUnread) feedsEvent {
+	event := feedsEvent{
+		Changed: []string{},
+		Feeds:   []feedUnread{},
+		Starred: s.stars.Count(),
+	}
+
+	current := map[string]bool{}
+
+	for _, f := range s.feeds.list.All() {
+		f.mu.RLock()
+		fu := feedUnread{
+			ID:       f.ID(),
+			Title:    f.Title(),
+			Category: f.Category,
+			Unread:   f.UnreadItemCount(),
+			Error:    f.HasRecentError(),
+		}
+		f.mu.RUnlock()
+
+		if last[fu.ID] != fu {
+			event.Changed = append(event.Changed, fu.ID)
+			last[fu.ID] = fu
+		}
+
+		current[fu.ID] = true
+		event.Feeds = append(event.Feeds, fu)
+	}
+
+	// deleted feeds have changed too
+	for id := range last {
+		if !current[id] {
+			event.Changed = append(event.Changed, id)
+			delete(last, id)
+		}
+	}
+
+	return event
+}
+
+// eventID identifies the state an event describes, so a reconnecting
+// browser (which sends it back as Last-Event-ID) only gets an event if
+// it has missed something.
+func (s *Service) eventID() string {
+	return strconv.FormatInt(s.getLastmodified().UnixNano(), 10)
+}
+
+func (s *Service) events(w http.ResponseWriter, req *http.Request) {
+	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
+
+	rc := http.NewResponseController(w)
+
+	// an open page keeps the feeds updating, as polling used to
+	s.recordActivity()
+	defer s.recordActivity()
+
+	changes := s.broker.subscribe()
+	defer s.broker.unsubscribe(changes)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx
+	w.WriteHeader(http.StatusOK)
+
+	last := map[string]feedUnread{}
+
+	send := func() error {
+		data, err := json.Marshal(s.feedsEvent(last))
+		if err != nil {
+			return fmt.Errorf("marshal event - %w", err)
+		}
+
+		return writeEvent(w, rc, fmt.Sprintf("id: %s\nevent: feeds\ndata: %s\n\n", s.eventID(), data))
+	}
+
+	// The page says which state it was rendered from, on reconnect the
+	// browser does.
+	since := req.Header.Get("Last-Event-ID")
+	if since == "" {
+		since = req.URL.Query().Get("since")
+	}
+
+	var err error
+	if since != "" && since != s.eventID() {
+		err = send()
+	} else {
+		s.feedsEvent(last) // so the first event only lists what's changed
+		err = writeEvent(w, rc, ": connected\n\n")
+	}
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for err == nil {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-changes:
+			select {
+			case <-time.After(eventsDebounce):
+			case <-req.Context().Done():
+				return
+			}
+
+			// anything published while we waited is in this event
+			select {
+			case <-changes:
+			default:
+			}
+
+			err = send()
+		case <-keepAlive.C:
+			err = writeEvent(w, rc, ": keep-alive\n\n")
+		}
+	}
+
+	logger.Debug("events stream closed", "error", err)
+}
+
+// writeEvent sends part of the stream, straight to the browser.
+func writeEvent(w http.ResponseWriter, rc *http.ResponseController, event string) error {
+	if _, err := fmt.Fprint(w, event); err != nil {
+		return fmt.Errorf("write event - %w", err)
+	}
+
+	if err := rc.Flush(); err != nil {
+		return fmt.Errorf("flush event - %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,224 @@
+package rssole
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func newEventsTestService(t *testing.T) (*Service, *httptest.Server) {
+	t.Helper()
+
+	svc := NewService()
+	svc.startOnce.Do(func() {}) // connecting mustn't start fetching the test feeds
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /events", svc.events)
+
+	ts := httptest.NewServer(compress(mux))
+	t.Cleanup(ts.Close)
+
+	return svc, ts
+}
+
+// connectEvents opens an /events stream, the returned func reads the next
+// message from it.
+func connectEvents(t *testing.T, ctx context.Context, url, lastEventID string) func() string {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { resp.Body.Close() })
+
+	if resp.Header.Get("Content-Type") != "text/event-stream" || resp.Header.Get("Content-Encoding") != "" {
+		t.Fatal("unexpected headers", resp.Header)
+	}
+
+	messages := make(chan string)
+
+	go func() {
+		r := bufio.NewReader(resp.Body)
+		message := ""
+
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			if line == "\n" {
+				messages <- message
+				message = ""
+
+				continue
+			}
+
+			message += line
+		}
+	}()
+
+	return func() string {
+		select {
+		case message := <-messages:
+			return message
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an event")
+		}
+
+		return ""
+	}
+}
+
+func eventData(t *testing.T, message string) feedsEvent {
+	t.Helper()
+
+	if !strings.Contains(message, "event: feeds\n") {
+		t.Fatal("expected a feeds event", message)
+	}
+
+	_, data, _ := strings.Cut(message, "data: ")
+
+	var event feedsEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		t.Fatal(err)
+	}
+
+	return event
+}
+
+func TestEvents(t *testing.T) {
+	svc, ts := newEventsTestService(t)
+
+	existing := &feed{URL: "http://example.com/existing", feed: &gofeed.Feed{}}
+	existing.Init()
+	svc.feeds.list.Add(existing)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	next := connectEvents(t, ctx, ts.URL+"/events", "")
+
+	if message := next(); message != ": connected\n" {
+		t.Fatal("expected a comment on connecting, got", message)
+	}
+
+	if svc.IsIdle() || svc.broker.connected() != 1 {
+		t.Fatal("expected an open stream to count as activity")
+	}
+
+	added := &feed{URL: "http://example.com/added", Name: "Added", feed: &gofeed.Feed{}}
+	added.Init()
+	svc.feeds.list.Add(added)
+
+	// several changes in quick succession are one event
+	svc.UpdateLastModified()
+	svc.UpdateLastModified()
+	svc.UpdateLastModified()
+
+	message := next()
+	if !strings.Contains(message, "id: "+svc.eventID()+"\n") {
+		t.Fatal("expected the event id to be the current state", message)
+	}
+
+	event := eventData(t, message)
+	if len(event.Changed) != 1 || event.Changed[0] != added.ID() || len(event.Feeds) != 2 {
+		t.Fatal("expected only the added feed to have changed", event)
+	}
+
+	svc.feeds.list.Remove(added.ID())
+	svc.UpdateLastModified()
+
+	event = eventData(t, next())
+	if len(event.Changed) != 1 || event.Changed[0] != added.ID() || len(event.Feeds) != 1 {
+		t.Fatal("expected the removed feed to have changed", event)
+	}
+
+	cancel()
+
+	for svc.broker.connected() != 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEvents_Reconnect(t *testing.T) {
+	svc, ts := newEventsTestService(t)
+	svc.UpdateLastModified()
+
+	// up to date, so nothing to send
+	next := connectEvents(t, t.Context(), ts.URL+"/events", svc.eventID())
+	if message := next(); message != ": connected\n" {
+		t.Fatal("expected no event when nothing was missed, got", message)
+	}
+
+	// missed something while disconnected
+	next = connectEvents(t, t.Context(), ts.URL+"/events?since=123", "")
+	eventData(t, next())
+}
+
+func TestEventBroker_Coalesces(t *testing.T) {
+	b := newEventBroker()
+	ch := b.subscribe()
+
+	b.publish()
+	b.publish() // mustn't block
+
+	<-ch
+
+	select {
+	case <-ch:
+		t.Fatal("expected publishes to coalesce")
+	default:
+	}
+
+	b.unsubscribe(ch)
+
+	if b.connected() != 0 {
+		t.Fatal("expected no subscribers")
+	}
+}
+
+func TestFeedsEvent_CategoryAndError(t *testing.T) {
+	svc := NewService()
+
+	f := &feed{URL: "http://example.com/feed", Category: "Old", feed: &gofeed.Feed{}}
+	f.Init()
+	svc.feeds.list.Add(f)
+
+	last := map[string]feedUnread{}
+	svc.feedsEvent(last)
+
+	f.Category = "New"
+
+	event := svc.feedsEvent(last)
+	if len(event.Changed) != 1 || event.Feeds[0].Category != "New" {
+		t.Fatal("expected a moved feed to have changed", event)
+	}
+
+	f.recordError()
+
+	event = svc.feedsEvent(last)
+	if len(event.Changed) != 1 || !event.Feeds[0].Error {
+		t.Fatal("expected a failing feed to have changed", event)
+	}
+}
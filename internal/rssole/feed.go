@@ -20,16 +20,20 @@ import (
 )
 
 type feed struct {
-	URL        string            `json:"url"`
-	Name       string            `json:"name,omitempty"`     // optional override name
-	Category   string            `json:"category,omitempty"` // optional grouping
-	Scrape     *scrape           `json:"scrape,omitempty"`
-	RecentLogs *limitLinesBuffer `json:"-"`
+	URL           string            `json:"url"`
+	Name          string            `json:"name,omitempty"`     // optional override name
+	Category      string            `json:"category,omitempty"` // optional grouping
+	Scrape        *scrape           `json:"scrape,omitempty"`
+	Filters       []*filterRule     `json:"filters,omitempty"`
+	UpdateSeconds int               `json:"update_seconds,omitempty"` // optional override of the global update time
+	FullContent   bool              `json:"full_content,omitempty"`   // fetch each item's article, see fullcontent.go
+	RecentLogs    *limitLinesBuffer `json:"-"`
 
 	ticker       *time.Ticker
 	stopCh       chan struct{}
 	updateCh     chan struct{}
-	updatePeriod time.Duration
+	updatePeriod time.Duration // the global update time, see schedule.go
+	tickPeriod   time.Duration // what the ticker is currently set to
 	lastPolled   time.Time
 	feed         *gofeed.Feed
 	mu           sync.RWMutex
@@ -42,10 +46,25 @@ type feed struct {
 
 	lastSuccess time.Time
 	lastError   time.Time
+	failures    int       // consecutive
+	notBefore   time.Time // the publisher asked us not to poll before this, see cachehints.go
 
 	// Dependencies injected via StartTickedUpdate
 	readCache ReadCache
+	archive   ItemArchive
 	activity  ActivityTracker
+
+	// pool shares fetching with other feeds for the same URL, nil for none
+	pool *feedPool
+
+	// config is where the global filters come from, nil for none
+	config *ConfigSection
+
+	// notifier is told about new items, nil for none
+	notifier Notifier
+
+	// articles caches the full content (see fullcontent.go)
+	articles *articleCache
 }
 
 var (
@@ -98,6 +117,7 @@ func (llw *limitLinesBuffer) Write(p []byte) (int, error) {
 }
 
 func (f *feed) Init() {
+	f.articles = newArticleCache() // until attached to the shared one
 	f.RecentLogs = &limitLinesBuffer{
 		MaxLines: maxRecentLogLines,
 		Buffer:   bytes.NewBufferString(""),
@@ -152,46 +172,80 @@ func (f *feed) Items() []*wrappedItem {
 	return *items
 }
 
+// Update fetches the feed and refreshes its items, along with those of
+// any followers (see feedPool).
 func (f *feed) Update() error {
+	feed, err := f.fetch()
+	if err != nil {
+		if errors.Is(err, ErrNotModified) {
+			for _, follower := range f.followers() {
+				follower.freshenUrlsInReadCache()
+			}
+		}
+
+		return err
+	}
+
+	f.apply(feed)
+
+	for _, follower := range f.followers() {
+		follower.apply(feed)
+	}
+
+	return nil
+}
+
+// fetch gets and parses the feed (or scrapes the website into a feed).
+func (f *feed) fetch() (*gofeed.Feed, error) {
 	var feed *gofeed.Feed
 
-	fp := gofeed.NewParser()
+	fp := newFeedParser()
+
+	// the url and scrape settings can be edited while we fetch
+	f.mu.RLock()
+	feedURL, scr := f.URL, f.Scrape
+	eTag, lastModified := f.eTag, f.lastModified
+	f.mu.RUnlock()
 
-	if f.Scrape != nil {
-		f.log.Info("Scraping website pages", "urls", f.Scrape.URLs)
+	if scr != nil {
+		f.log.Info("Scraping website pages", "urls", scr.URLs)
 
-		pseudoRss, err := f.Scrape.GeneratePseudoRssFeed()
+		pseudoRss, err := scr.GeneratePseudoRssFeed()
 		if err != nil {
-			return fmt.Errorf("rss GeneratePseudoRssFeed %s %w", f.URL, err)
+			return nil, fmt.Errorf("rss GeneratePseudoRssFeed %s %w", feedURL, err)
 		}
 
 		f.log.Info("Parsing pseudo feed")
 
 		feed, err = fp.ParseString(pseudoRss)
 		if err != nil {
-			return fmt.Errorf("rss parsestring %s %w", f.URL, err)
+			return nil, fmt.Errorf("rss parsestring %s %w", feedURL, err)
 		}
 	} else {
-		f.log.Info("Fetching and parsing feed", "url", f.URL)
+		f.log.Info("Fetching and parsing feed", "url", feedURL)
 
-		req, err := http.NewRequest(http.MethodGet, f.URL, nil)
+		req, err := http.NewRequest(http.MethodGet, feedURL, nil)
 		if err != nil {
-			return fmt.Errorf("cannot create new request: %w", err)
+			return nil, fmt.Errorf("cannot create new request: %w", err)
 		}
 
 		req.Header.Set("User-Agent", "Gofeed/1.0")
 
-		if f.eTag != "" {
-			req.Header.Set("If-None-Match", fmt.Sprintf(`"%s"`, f.eTag))
+		if eTag != "" {
+			req.Header.Set("If-None-Match", fmt.Sprintf(`"%s"`, eTag))
 		}
 
-		req.Header.Set("If-Modified-Since", f.lastModified.In(gmtTimeZoneLocation).Format(time.RFC1123))
+		req.Header.Set("If-Modified-Since", lastModified.In(gmtTimeZoneLocation).Format(time.RFC1123))
 
 		resp, err := httpClient.Do(req)
 		if err != nil {
-			return fmt.Errorf("unable to do request: %w", err)
+			return nil, fmt.Errorf("unable to do request: %w", err)
 		}
 
+		// whatever happens, respect how long the publisher wants us to wait
+		delay := headerDelay(resp.Header, time.Now())
+		defer func() { f.deferUntil(delay) }()
+
 		if resp != nil {
 			defer func() {
 				ce := resp.Body.Close()
@@ -204,11 +258,11 @@ func (f *feed) Update() error {
 		if resp.StatusCode == http.StatusNotModified {
 			f.freshenUrlsInReadCache()
 
-			return ErrNotModified
+			return nil, ErrNotModified
 		}
 
 		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			return gofeed.HTTPError{
+			return nil, gofeed.HTTPError{
 				StatusCode: resp.StatusCode,
 				Status:     resp.Status,
 			}
@@ -216,38 +270,68 @@ func (f *feed) Update() error {
 
 		feed, err = fp.Parse(resp.Body)
 		if err != nil {
-			return fmt.Errorf("rss parseurl %s %w", f.URL, err)
+			return nil, fmt.Errorf("rss parseurl %s %w", feedURL, err)
 		}
 
-		if eTag := resp.Header.Get("Etag"); eTag != "" {
-			f.eTag = eTag
-		}
+		delay = max(delay, feedDelay(feed))
 
-		if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
-			parsed, err := time.ParseInLocation(time.RFC1123, lastModified, gmtTimeZoneLocation)
-			if err == nil {
-				f.lastModified = parsed
+		f.mu.Lock()
+		// they're no use if the url was changed while we fetched
+		if f.URL == feedURL {
+			if eTag := resp.Header.Get("Etag"); eTag != "" {
+				f.eTag = eTag
+			}
+
+			if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+				parsed, err := time.ParseInLocation(time.RFC1123, lastModified, gmtTimeZoneLocation)
+				if err == nil {
+					f.lastModified = parsed
+				}
 			}
 		}
+		f.mu.Unlock()
 	}
 
+	return feed, nil
+}
+
+// apply refreshes the items from a freshly fetched feed.
+func (f *feed) apply(feed *gofeed.Feed) {
+	feed = f.withFullContent(feed)
+
 	f.mu.Lock()
 	f.feed = feed
 	f.mu.Unlock()
 
-	newItems := make([]*wrappedItem, len(feed.Items))
-
 	f.log.Info("Items in feed", "length", len(feed.Items))
 
-	for idx, item := range feed.Items {
+	items := feed.Items
+	if f.archive != nil {
+		items = f.archive.Merge(f.ID(), feed.Items)
+		f.log.Info("Items including archive", "length", len(items))
+	}
+
+	rules := f.filterRules()
+	newItems := make([]*wrappedItem, 0, len(items))
+
+	for _, item := range items {
 		wItem := &wrappedItem{
 			Feed: f,
 			Item: item,
 		}
 		wItem.IsUnread = f.readCache.IsUnread(wItem.MarkReadID())
-		newItems[idx] = wItem
+
+		if f.applyFilters(wItem, rules) {
+			newItems = append(newItems, wItem)
+		}
+	}
+
+	if hidden := len(items) - len(newItems); hidden > 0 {
+		f.log.Info("Items hidden by filters", "hidden", hidden)
 	}
 
+	f.notifyNewItems(newItems)
+
 	sort.Slice(newItems, func(i, j int) bool {
 		// unread always higher than read
 		if newItems[i].IsUnread && !newItems[j].IsUnread {
@@ -283,9 +367,39 @@ func (f *feed) Update() error {
 
 	f.readCache.Persist()
 
+	if f.archive != nil {
+		f.archive.Persist()
+	}
+
 	f.activity.UpdateLastModified()
+}
 
-	return nil
+// notifyNewItems tells the notifier about items that weren't there last
+// update. Nothing is new on the first update, otherwise every restart
+// would look like a flood of new items.
+func (f *feed) notifyNewItems(items []*wrappedItem) {
+	previous := f.wrappedItems.Load()
+	if f.notifier == nil || previous == nil {
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, i := range *previous {
+		seen[i.MarkReadID()] = true
+	}
+
+	added := []*wrappedItem{}
+
+	for _, i := range items {
+		if !seen[i.MarkReadID()] {
+			added = append(added, i)
+		}
+	}
+
+	if len(added) > 0 {
+		f.log.Info("New items", "count", len(added))
+		f.notifier.NewItems(f, added)
+	}
 }
 
 func (f *feed) freshenUrlsInReadCache() {
@@ -296,19 +410,34 @@ func (f *feed) freshenUrlsInReadCache() {
 	}
 }
 
-func (f *feed) StartTickedUpdate(updateTime time.Duration, readCache ReadCache, activity ActivityTracker) {
+func (f *feed) StartTickedUpdate(updateTime time.Duration, readCache ReadCache, archive ItemArchive, activity ActivityTracker) {
 	if f.ticker != nil {
 		return // already running
 	}
 
 	f.readCache = readCache
+	f.archive = archive
 	f.activity = activity
 
-	f.log.Info("Starting feed update ticker", "duration", updateTime)
-	f.ticker = time.NewTicker(updateTime)
+	if f.pool != nil && f.pool.join(f) {
+		f.log.Info("Following another feed with the same url")
+
+		return
+	}
+
+	f.startTicker(updateTime)
+}
+
+func (f *feed) startTicker(updateTime time.Duration) {
+	f.mu.Lock()
+	f.updatePeriod = updateTime
+	f.tickPeriod = f.nextPeriod()
+	f.mu.Unlock()
+
+	f.log.Info("Starting feed update ticker", "duration", f.tickPeriod)
+	f.ticker = time.NewTicker(f.tickPeriod)
 	f.stopCh = make(chan struct{})
 	f.updateCh = make(chan struct{}, 1)
-	f.updatePeriod = updateTime
 
 	stopCh := f.stopCh
 	ticker := f.ticker
@@ -321,15 +450,17 @@ func (f *feed) StartTickedUpdate(updateTime time.Duration, readCache ReadCache,
 			case <-stopCh:
 				return
 			case <-ticker.C:
-				if activity.IsIdle() {
+				if f.isIdle() {
 					f.log.Info("Skipping update, no active clients")
 
 					continue
 				}
 
 				f.doUpdate()
+				f.reschedule(ticker)
 			case <-updateCh:
 				f.doUpdate()
+				f.reschedule(ticker)
 			}
 		}
 	}()
@@ -338,26 +469,52 @@ func (f *feed) StartTickedUpdate(updateTime time.Duration, readCache ReadCache,
 	f.RequestUpdate()
 }
 
+// isIdle is true if nobody is reading this feed (or any of its followers).
+func (f *feed) isIdle() bool {
+	for _, follower := range f.followers() {
+		if !follower.activity.IsIdle() {
+			return false
+		}
+	}
+
+	return f.activity.IsIdle()
+}
+
 func (f *feed) doUpdate() {
-	if time.Since(f.lastPolled) < f.updatePeriod-time.Second {
-		return // too soon
+	f.mu.RLock()
+	tooSoon := f.tooSoon()
+	f.mu.RUnlock()
+
+	if tooSoon {
+		return
 	}
 
+	f.mu.Lock()
 	f.lastPolled = time.Now()
+	f.mu.Unlock()
+
+	err := f.Update()
 
-	if err := f.Update(); err != nil {
-		if !errors.Is(err, ErrNotModified) {
-			f.log.Error("update failed", "error", err)
-			f.recordError()
+	for _, fd := range append([]*feed{f}, f.followers()...) {
+		switch {
+		case err == nil:
+			fd.recordSuccess()
+		case !errors.Is(err, ErrNotModified):
+			fd.log.Error("update failed", "error", err)
+			fd.recordError()
 		}
-	} else {
-		f.recordSuccess()
 	}
 }
 
 // RequestUpdate signals the feed to update. Non-blocking; if an update
 // is already pending, this is a no-op.
 func (f *feed) RequestUpdate() {
+	if leader := f.leader(); leader != nil {
+		leader.RequestUpdate()
+
+		return
+	}
+
 	select {
 	case f.updateCh <- struct{}{}:
 	default:
@@ -365,15 +522,23 @@ func (f *feed) RequestUpdate() {
 	}
 }
 
+// ChangeTickedUpdate changes the global update time the feed's own
+// schedule is based on (see schedule.go).
 func (f *feed) ChangeTickedUpdate(d time.Duration) {
 	if f.ticker != nil {
-		f.log.Info("Update ticker", "update", d)
-		f.ticker.Reset(d)
+		f.mu.Lock()
 		f.updatePeriod = d
+		f.mu.Unlock()
+
+		f.reschedule(f.ticker)
 	}
 }
 
 func (f *feed) StopTickedUpdate() {
+	if f.pool != nil {
+		f.pool.leave(f)
+	}
+
 	if f.ticker != nil {
 		f.log.Info("Stopped update ticker")
 		f.ticker.Stop()
@@ -393,12 +558,14 @@ func (f *feed) ID() string {
 func (f *feed) recordSuccess() {
 	f.mu.Lock()
 	f.lastSuccess = time.Now()
+	f.failures = 0
 	f.mu.Unlock()
 }
 
 func (f *feed) recordError() {
 	f.mu.Lock()
 	f.lastError = time.Now()
+	f.failures++
 	f.mu.Unlock()
 }
 
@@ -237,7 +237,7 @@ func TestStartTickedUpdate(t *testing.T) {
 	}
 	feed.Init()
 
-	feed.StartTickedUpdate(10*time.Millisecond, mockRC, mockAT)
+	feed.StartTickedUpdate(10*time.Millisecond, mockRC, nil, mockAT)
 	time.Sleep(45 * time.Millisecond)
 	feed.StopTickedUpdate()
 
@@ -278,3 +278,40 @@ func TestLog_ExceedMaxLines(t *testing.T) {
 		t.Fatal("expected to find line 2 in:", feed.RecentLogs.String())
 	}
 }
+
+func TestUpdate_ArchiveKeepsDroppedItems(t *testing.T) {
+	mockRC, mockAT, teardown := feedSetUpTearDown(t)
+	defer teardown(t)
+
+	items := `<item><title>Title 1</title><link>http://title1.com/</link></item>
+<item><title>Title 2</title><link>http://title2.com/</link></item>`
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8" ?>
+<rss version="2.0"><channel><title>Feed Title</title>`+items+`</channel></rss>`)
+	}))
+	defer ts.Close()
+
+	feed := &feed{
+		URL:       ts.URL,
+		readCache: mockRC,
+		archive:   &itemArchive{Retention: time.Hour},
+		activity:  mockAT,
+	}
+	feed.Init()
+
+	if err := feed.Update(); err != nil {
+		t.Fatal("unexpected error for a valid", err)
+	}
+
+	// Title 1 drops out of the feed upstream
+	items = `<item><title>Title 2</title><link>http://title2.com/</link></item>`
+
+	if err := feed.Update(); err != nil {
+		t.Fatal("unexpected error for a valid", err)
+	}
+
+	if len(feed.Items()) != 2 {
+		t.Fatal("expected dropped item to be kept by the archive, got", len(feed.Items()))
+	}
+}
@@ -0,0 +1,138 @@
+package rssole
+
+import (
+	"slices"
+	"sync"
+)
+
+// feedPool shares fetching between feeds with the same URL (e.g. when
+// several users subscribe to it). The first feed to start becomes the
+// leader and runs the only ticker for that URL, the rest follow it and
+// are updated from its fetches. Followers use the leader's scrape settings.
+type feedPool struct {
+	leaders   map[string]*feed  // url -> leader
+	followers map[*feed][]*feed // leader -> followers
+	leaderOf  map[*feed]*feed   // follower -> leader
+	mu        sync.Mutex
+}
+
+func newFeedPool() *feedPool {
+	return &feedPool{
+		leaders:   map[string]*feed{},
+		followers: map[*feed][]*feed{},
+		leaderOf:  map[*feed]*feed{},
+	}
+}
+
+// join makes f follow the feed already fetching its URL, returning false
+// (and making f the leader) if there isn't one.
+func (p *feedPool) join(f *feed) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.leaderOf[f] != nil {
+		return true // already following
+	}
+
+	if leader, found := p.leaders[f.URL]; found && leader != f {
+		p.followers[leader] = append(p.followers[leader], f)
+		p.leaderOf[f] = leader
+
+		return true
+	}
+
+	p.leaders[f.URL] = f
+
+	return false
+}
+
+// leave removes f from the pool. If f was a leader its first follower
+// takes over fetching.
+func (p *feedPool) leave(f *feed) {
+	p.mu.Lock()
+
+	if leader, found := p.leaderOf[f]; found {
+		delete(p.leaderOf, f)
+		p.followers[leader] = slices.DeleteFunc(slices.Clone(p.followers[leader]), func(fd *feed) bool {
+			return fd == f
+		})
+		p.mu.Unlock()
+
+		return
+	}
+
+	// the url may have changed since joining, so find f by value
+	url, found := "", false
+
+	for u, leader := range p.leaders {
+		if leader == f {
+			url, found = u, true
+
+			break
+		}
+	}
+
+	if !found {
+		p.mu.Unlock()
+
+		return
+	}
+
+	delete(p.leaders, url)
+
+	followers := p.followers[f]
+	delete(p.followers, f)
+
+	var next *feed
+
+	if len(followers) > 0 {
+		next = followers[0]
+		delete(p.leaderOf, next)
+
+		p.leaders[url] = next
+		p.followers[next] = followers[1:]
+
+		for _, follower := range followers[1:] {
+			p.leaderOf[follower] = next
+		}
+	}
+
+	p.mu.Unlock()
+
+	if next != nil {
+		next.log.Info("Taking over fetching from a removed feed")
+		next.startTicker(f.updatePeriod)
+	}
+}
+
+// followersOf returns the feeds following f.
+func (p *feedPool) followersOf(f *feed) []*feed {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return slices.Clone(p.followers[f])
+}
+
+// leaderOfFeed returns the feed f is following, or nil.
+func (p *feedPool) leaderOfFeed(f *feed) *feed {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.leaderOf[f]
+}
+
+func (f *feed) followers() []*feed {
+	if f.pool == nil {
+		return nil
+	}
+
+	return f.pool.followersOf(f)
+}
+
+func (f *feed) leader() *feed {
+	if f.pool == nil {
+		return nil
+	}
+
+	return f.pool.leaderOfFeed(f)
+}
@@ -0,0 +1,102 @@
+package rssole
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newPoolTestServer(t *testing.T, fetches *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8" ?>
+<rss version="2.0"><channel><title>Feed Title</title>
+<item><title>Title 1</title><link>http://title1.com/</link></item>
+</channel></rss>`)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func waitForItems(t *testing.T, f *feed) {
+	t.Helper()
+
+	for range 100 {
+		if len(f.Items()) > 0 {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("timed out waiting for items")
+}
+
+func TestFeedPool_SharesFetches(t *testing.T) {
+	var fetches atomic.Int32
+
+	ts := newPoolTestServer(t, &fetches)
+	pool := newFeedPool()
+
+	leader := &feed{URL: ts.URL, pool: pool}
+	leader.Init()
+
+	follower := &feed{URL: ts.URL, pool: pool}
+	follower.Init()
+
+	follower.StartTickedUpdate(time.Hour, &mockReadCache{}, nil, &mockActivityTracker{})
+	defer follower.StopTickedUpdate()
+
+	leader.StartTickedUpdate(time.Hour, &mockReadCache{}, nil, &mockActivityTracker{})
+	defer leader.StopTickedUpdate()
+
+	waitForItems(t, follower)
+	waitForItems(t, leader)
+
+	if fetches.Load() != 1 {
+		t.Fatal("expected the url to be fetched once, got", fetches.Load())
+	}
+
+	if follower.ticker != nil && leader.ticker != nil {
+		t.Fatal("expected only one ticker for the url")
+	}
+
+	if leader.Items()[0] == follower.Items()[0] {
+		t.Fatal("expected each feed to have its own items")
+	}
+}
+
+func TestFeedPool_FollowerTakesOver(t *testing.T) {
+	var fetches atomic.Int32
+
+	ts := newPoolTestServer(t, &fetches)
+	pool := newFeedPool()
+
+	first := &feed{URL: ts.URL, pool: pool}
+	first.Init()
+	first.StartTickedUpdate(time.Hour, &mockReadCache{}, nil, &mockActivityTracker{})
+
+	second := &feed{URL: ts.URL, pool: pool}
+	second.Init()
+	second.StartTickedUpdate(time.Hour, &mockReadCache{}, nil, &mockActivityTracker{})
+
+	defer second.StopTickedUpdate()
+
+	if second.leader() != first {
+		t.Fatal("expected the second feed to follow the first")
+	}
+
+	first.StopTickedUpdate()
+
+	if second.ticker == nil || second.leader() != nil {
+		t.Fatal("expected the second feed to take over fetching")
+	}
+
+	waitForItems(t, second)
+}
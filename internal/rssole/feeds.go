@@ -24,6 +24,9 @@ type feeds struct {
 	UpdateTime time.Duration `json:"-"`
 	filename   string
 	list       *feedList
+	pool       *feedPool // shared with other users' feeds, nil for no sharing
+	notifier   Notifier  // told about new items, nil for none
+	articles   *articleCache
 }
 
 // feedsJSON is used for JSON serialization only.
@@ -66,16 +69,39 @@ func (f *feeds) UnmarshalJSON(data []byte) error {
 }
 
 type ConfigSection struct {
-	Listen        string `json:"listen"`
-	UpdateSeconds int    `json:"update_seconds"`
+	Listen        string        `json:"listen"`
+	UpdateSeconds int           `json:"update_seconds"`
+	ArchiveDays   int           `json:"archive_days,omitempty"` // 0 disables the item archive
+	Sync          *SyncConfig   `json:"sync,omitempty"`         // nil disables the sync APIs
+	Auth          *AuthConfig   `json:"auth,omitempty"`         // nil disables authentication
+	Filters       []*filterRule `json:"filters,omitempty"`      // applied to every feed
+	Webhooks      []*webhook    `json:"webhooks,omitempty"`     // told about new items
+}
+
+// SyncConfig holds the credentials third-party apps use with the sync APIs (e.g. Fever).
+type SyncConfig struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
 }
 
 func (f *feeds) All() []*feed {
 	return f.list.All()
 }
 
-func (f *feeds) addFeed(feedToAdd *feed, readCache ReadCache, activity ActivityTracker) {
-	feedToAdd.StartTickedUpdate(f.UpdateTime, readCache, activity)
+// attach gives the feed the things it shares with the rest of the feeds.
+func (f *feeds) attach(fd *feed) {
+	fd.pool = f.pool
+	fd.config = &f.Config
+	fd.notifier = f.notifier
+
+	if f.articles != nil {
+		fd.articles = f.articles
+	}
+}
+
+func (f *feeds) addFeed(feedToAdd *feed, readCache ReadCache, archive ItemArchive, activity ActivityTracker) {
+	f.attach(feedToAdd)
+	feedToAdd.StartTickedUpdate(f.UpdateTime, readCache, archive, activity)
 	f.list.Add(feedToAdd)
 }
 
@@ -107,6 +133,45 @@ func (f *feeds) readFeedsFile(filename string) error {
 		return fmt.Errorf("error unmarshalling JSON: %w", err)
 	}
 
+	if err := f.validateFilters(); err != nil {
+		return err
+	}
+
+	return f.validateScrapes()
+}
+
+// validateScrapes runs the same checks on every scrape as the preview does,
+// so a broken one is found at startup rather than on its first update.
+func (f *feeds) validateScrapes() error {
+	for _, fd := range f.list.All() {
+		if fd.Scrape == nil {
+			continue
+		}
+
+		if err := fd.Scrape.Validate(); err != nil {
+			return fmt.Errorf("scrape for %s - %w", fd.URL, err)
+		}
+	}
+
+	return nil
+}
+
+// validateFilters checks every global and per-feed filter rule.
+func (f *feeds) validateFilters() error {
+	for _, rule := range f.Config.Filters {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("global filter - %w", err)
+		}
+	}
+
+	for _, fd := range f.list.All() {
+		for _, rule := range fd.Filters {
+			if err := rule.Validate(); err != nil {
+				return fmt.Errorf("filter for %s - %w", fd.URL, err)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -137,12 +202,13 @@ func (f *feeds) FeedTree() map[string][]*feed {
 	return cats
 }
 
-func (f *feeds) BeginFeedUpdates(readCache ReadCache, activity ActivityTracker) {
+func (f *feeds) BeginFeedUpdates(readCache ReadCache, archive ItemArchive, activity ActivityTracker) {
 	// ignore cert errors
 	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 
 	for _, feed := range f.list.All() {
-		feed.StartTickedUpdate(f.UpdateTime, readCache, activity)
+		f.attach(feed)
+		feed.StartTickedUpdate(f.UpdateTime, readCache, archive, activity)
 	}
 }
 
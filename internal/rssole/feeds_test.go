@@ -3,6 +3,7 @@ package rssole
 import (
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -79,6 +80,27 @@ func TestReadFeedsFile_BadJson(t *testing.T) {
 	}
 }
 
+func TestReadFeedsFile_InvalidScrape(t *testing.T) {
+	for _, scrape := range []string{
+		`{"urls":["http://example.com/"],"title":".title"}`,
+		`{"urls":["http://example.com/"],"item":"[[","title":".title"}`,
+		`{"urls":[],"item":".item","title":".title"}`,
+		`{"urls":["http://example.com/"],"json":{"items":"posts"}}`,
+	} {
+		filename := filepath.Join(t.TempDir(), "rssole.json")
+
+		err := os.WriteFile(filename, []byte(`{"config":{},"feeds":[{"url":"scraped","scrape":`+scrape+`}]}`), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f := feeds{}
+		if err := f.readFeedsFile(filename); err == nil {
+			t.Fatal("expected an invalid scrape to fail loading", scrape)
+		}
+	}
+}
+
 func TestReadFeedsFile_NoSuchFile(t *testing.T) {
 	f := feeds{}
 
@@ -103,8 +125,8 @@ func TestAddFeed(t *testing.T) {
 	f2 := &feed{}
 	f2.Init()
 
-	f.addFeed(f1, mockRC, mockAT)
-	f.addFeed(f2, mockRC, mockAT)
+	f.addFeed(f1, mockRC, nil, mockAT)
+	f.addFeed(f2, mockRC, nil, mockAT)
 
 	// Clean up tickers
 	f1.StopTickedUpdate()
@@ -133,9 +155,9 @@ func TestDelFeed(t *testing.T) {
 	fd3 := &feed{URL: "3"}
 	fd3.Init()
 
-	f.addFeed(fd1, mockRC, mockAT)
-	f.addFeed(fd2, mockRC, mockAT)
-	f.addFeed(fd3, mockRC, mockAT)
+	f.addFeed(fd1, mockRC, nil, mockAT)
+	f.addFeed(fd2, mockRC, nil, mockAT)
+	f.addFeed(fd3, mockRC, nil, mockAT)
 
 	f.delFeed(fd1.ID())
 
@@ -166,9 +188,9 @@ func TestGetFeedByID(t *testing.T) {
 	f3 := &feed{URL: "3"}
 	f3.Init()
 
-	f.addFeed(f1, mockRC, mockAT)
-	f.addFeed(f2, mockRC, mockAT)
-	f.addFeed(f3, mockRC, mockAT)
+	f.addFeed(f1, mockRC, nil, mockAT)
+	f.addFeed(f2, mockRC, nil, mockAT)
+	f.addFeed(f3, mockRC, nil, mockAT)
 
 	// Clean up tickers
 	defer f1.StopTickedUpdate()
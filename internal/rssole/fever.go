@@ -0,0 +1,356 @@
+package rssole
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// The Fever API (https://feedafever.com/api) lets third-party apps such as
+// Reeder and Unread sync with rssole. Fever needs integer ids, which come from
+// Service.ids.
+
+const (
+	feverAPIVersion = 3
+	feverMaxItems   = 50
+	feverAllGroupID = 0 // "Kindling", every feed
+)
+
+type feverGroup struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+type feverFeedsGroup struct {
+	GroupID int64  `json:"group_id"`
+	FeedIDs string `json:"feed_ids"`
+}
+
+type feverFeed struct {
+	ID                int64  `json:"id"`
+	FaviconID         int64  `json:"favicon_id"`
+	Title             string `json:"title"`
+	URL               string `json:"url"`
+	SiteURL           string `json:"site_url"`
+	IsSpark           int    `json:"is_spark"`
+	LastUpdatedOnTime int64  `json:"last_updated_on_time"`
+}
+
+type feverItem struct {
+	ID            int64  `json:"id"`
+	FeedID        int64  `json:"feed_id"`
+	Title         string `json:"title"`
+	Author        string `json:"author"`
+	HTML          string `json:"html"`
+	URL           string `json:"url"`
+	IsSaved       int    `json:"is_saved"`
+	IsRead        int    `json:"is_read"`
+	CreatedOnTime int64  `json:"created_on_time"`
+}
+
+func feverAPIKey(username, password string) string {
+	hash := md5.Sum([]byte(username + ":" + password))
+
+	return hex.EncodeToString(hash[:])
+}
+
+func joinIDs(ids []int64) string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = strconv.FormatInt(id, 10)
+	}
+
+	return strings.Join(strs, ",")
+}
+
+func (s *Service) feverAuthenticated(req *http.Request) bool {
+	sync := s.feeds.Config.Sync
+	if sync == nil || sync.Username == "" {
+		return false
+	}
+
+	expected := feverAPIKey(sync.Username, sync.Password)
+	given := strings.ToLower(req.FormValue("api_key"))
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(given)) == 1
+}
+
+func (s *Service) fever(w http.ResponseWriter, req *http.Request) {
+	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
+
+	if err := req.ParseForm(); err != nil {
+		logger.Error("ParseForm", "error", err)
+	}
+
+	resp := map[string]any{
+		"api_version": feverAPIVersion,
+		"auth":        0,
+	}
+
+	if !s.feverAuthenticated(req) {
+		writeJSON(w, http.StatusOK, resp)
+
+		return
+	}
+
+	// a sync app may be the only client, so it has to keep the feeds updating
+	s.recordActivity()
+
+	resp["auth"] = 1
+	resp["last_refreshed_on_time"] = s.getLastmodified().Unix()
+
+	idx := s.numericIndex()
+
+	if req.Form.Has("mark") {
+		s.feverMark(req, idx)
+
+		// let the client know the outcome
+		resp["unread_item_ids"] = s.feverUnreadItemIDs(idx)
+		resp["saved_item_ids"] = s.feverSavedItemIDs(idx)
+	}
+
+	if req.Form.Has("groups") {
+		resp["groups"] = s.feverGroups(idx)
+		resp["feeds_groups"] = s.feverFeedsGroups(idx)
+	}
+
+	if req.Form.Has("feeds") {
+		resp["feeds"] = s.feverFeeds(idx)
+		resp["feeds_groups"] = s.feverFeedsGroups(idx)
+	}
+
+	if req.Form.Has("favicons") {
+		resp["favicons"] = []any{}
+	}
+
+	if req.Form.Has("links") {
+		resp["links"] = []any{}
+	}
+
+	if req.Form.Has("items") {
+		resp["items"] = s.feverItems(req, idx)
+		resp["total_items"] = len(idx.refs)
+	}
+
+	if req.Form.Has("unread_item_ids") {
+		resp["unread_item_ids"] = s.feverUnreadItemIDs(idx)
+	}
+
+	if req.Form.Has("saved_item_ids") {
+		resp["saved_item_ids"] = s.feverSavedItemIDs(idx)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Service) feverGroups(idx *numericIndex) []feverGroup {
+	groups := []feverGroup{}
+	for id, title := range idx.groups {
+		groups = append(groups, feverGroup{ID: id, Title: title})
+	}
+
+	sort.Slice(groups, func(a, b int) bool { return groups[a].ID < groups[b].ID })
+
+	return groups
+}
+
+func (s *Service) feverFeedsGroups(idx *numericIndex) []feverFeedsGroup {
+	feedIDs := map[int64][]int64{}
+
+	for feedID, f := range idx.feeds {
+		if f.Category != "" {
+			groupID := s.ids.Get(categoryKey(f.Category))
+			feedIDs[groupID] = append(feedIDs[groupID], feedID)
+		}
+	}
+
+	feedsGroups := []feverFeedsGroup{}
+
+	for groupID, ids := range feedIDs {
+		sort.Slice(ids, func(a, b int) bool { return ids[a] < ids[b] })
+		feedsGroups = append(feedsGroups, feverFeedsGroup{GroupID: groupID, FeedIDs: joinIDs(ids)})
+	}
+
+	sort.Slice(feedsGroups, func(a, b int) bool { return feedsGroups[a].GroupID < feedsGroups[b].GroupID })
+
+	return feedsGroups
+}
+
+func (s *Service) feverFeeds(idx *numericIndex) []feverFeed {
+	feeds := []feverFeed{}
+
+	for id, f := range idx.feeds {
+		f.mu.RLock()
+		feeds = append(feeds, feverFeed{
+			ID:                id,
+			Title:             f.Title(),
+			URL:               f.URL,
+			SiteURL:           f.Link(),
+			LastUpdatedOnTime: f.lastSuccess.Unix(),
+		})
+		f.mu.RUnlock()
+	}
+
+	sort.Slice(feeds, func(a, b int) bool { return feeds[a].ID < feeds[b].ID })
+
+	return feeds
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+func (s *Service) feverItem(ref *numericRef) feverItem {
+	author := ""
+	if ref.Item.Author != nil {
+		author = ref.Item.Author.Name
+	}
+
+	return feverItem{
+		ID:            ref.ID,
+		FeedID:        ref.FeedID,
+		Title:         ref.Item.Title,
+		Author:        author,
+		HTML:          ref.Item.Description(),
+		URL:           ref.Item.Link,
+		IsSaved:       boolToInt(s.stars.IsStarred(ref.Item.ID())),
+		IsRead:        boolToInt(!s.readLut.IsUnread(ref.Item.MarkReadID())),
+		CreatedOnTime: itemTime(ref.Item).Unix(),
+	}
+}
+
+func parseIDs(raw string) []int64 {
+	ids := []int64{}
+
+	for _, str := range strings.Split(raw, ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(str), 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// feverItems pages through items by id, as described in the Fever API.
+func (s *Service) feverItems(req *http.Request, idx *numericIndex) []feverItem {
+	items := []feverItem{}
+
+	switch {
+	case req.Form.Has("with_ids"):
+		for _, id := range parseIDs(req.FormValue("with_ids")) {
+			if ref, found := idx.byID[id]; found && len(items) < feverMaxItems {
+				items = append(items, s.feverItem(ref))
+			}
+		}
+	case req.Form.Has("max_id"):
+		maxID, _ := strconv.ParseInt(req.FormValue("max_id"), 10, 64)
+
+		for i := len(idx.refs) - 1; i >= 0 && len(items) < feverMaxItems; i-- {
+			if idx.refs[i].ID < maxID {
+				items = append(items, s.feverItem(idx.refs[i]))
+			}
+		}
+	default:
+		sinceID, _ := strconv.ParseInt(req.FormValue("since_id"), 10, 64)
+
+		for _, ref := range idx.refs {
+			if ref.ID > sinceID && len(items) < feverMaxItems {
+				items = append(items, s.feverItem(ref))
+			}
+		}
+	}
+
+	return items
+}
+
+func (s *Service) feverUnreadItemIDs(idx *numericIndex) string {
+	ids := []int64{}
+
+	for _, ref := range idx.refs {
+		if s.readLut.IsUnread(ref.Item.MarkReadID()) {
+			ids = append(ids, ref.ID)
+		}
+	}
+
+	return joinIDs(ids)
+}
+
+func (s *Service) feverSavedItemIDs(idx *numericIndex) string {
+	ids := []int64{}
+
+	for _, ref := range idx.refs {
+		if s.stars.IsStarred(ref.Item.ID()) {
+			ids = append(ids, ref.ID)
+		}
+	}
+
+	return joinIDs(ids)
+}
+
+// feverMark handles mark=item|feed|group.
+func (s *Service) feverMark(req *http.Request, idx *numericIndex) {
+	id, _ := strconv.ParseInt(req.FormValue("id"), 10, 64)
+	as := req.FormValue("as")
+
+	switch req.FormValue("mark") {
+	case "item":
+		ref, found := idx.byID[id]
+		if !found {
+			return
+		}
+
+		switch as {
+		case "read":
+			s.setRead(ref, true)
+		case "unread":
+			s.setRead(ref, false)
+		case "saved":
+			s.stars.Star(ref.Item)
+		case "unsaved":
+			s.stars.Unstar(ref.Item.ID())
+		}
+	case "feed", "group":
+		if as != "read" {
+			return
+		}
+
+		// only mark what the client had seen at the time
+		before := time.Now()
+		if raw := req.FormValue("before"); raw != "" {
+			if secs, err := strconv.ParseInt(raw, 10, 64); err == nil {
+				before = time.Unix(secs, 0)
+			}
+		}
+
+		for _, ref := range idx.refs {
+			if ref.Feed == nil || itemTime(ref.Item).After(before) {
+				continue
+			}
+
+			// an unknown (or stale) group id mustn't match the uncategorised feeds
+			category, isGroup := idx.groups[id]
+
+			inFeed := req.FormValue("mark") == "feed" && ref.FeedID == id
+			inGroup := req.FormValue("mark") == "group" &&
+				(id == feverAllGroupID || (isGroup && category == ref.Feed.Category))
+
+			if inFeed || inGroup {
+				s.setRead(ref, true)
+			}
+		}
+	}
+
+	s.readLut.Persist()
+	s.stars.Persist()
+}
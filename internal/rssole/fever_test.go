@@ -0,0 +1,230 @@
+package rssole
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type feverTestResponse struct {
+	APIVersion    int               `json:"api_version"`
+	Auth          int               `json:"auth"`
+	Groups        []feverGroup      `json:"groups"`
+	FeedsGroups   []feverFeedsGroup `json:"feeds_groups"`
+	Feeds         []feverFeed       `json:"feeds"`
+	Items         []feverItem       `json:"items"`
+	TotalItems    int               `json:"total_items"`
+	UnreadItemIDs string            `json:"unread_item_ids"`
+	SavedItemIDs  string            `json:"saved_item_ids"`
+}
+
+func newFeverTestService(t *testing.T) (*Service, *feed) {
+	t.Helper()
+
+	svc, f, _ := newAPITestService(t)
+	svc.feeds.Config.Sync = &SyncConfig{Username: "user", Password: "pass"}
+
+	return svc, f
+}
+
+func feverRequest(t *testing.T, svc *Service, query string, form url.Values) feverTestResponse {
+	t.Helper()
+
+	if form == nil {
+		form = url.Values{}
+	}
+
+	if !form.Has("api_key") {
+		form.Set("api_key", feverAPIKey("user", "pass"))
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "/fever/?api&"+query, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	svc.fever(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var resp feverTestResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	return resp
+}
+
+func TestFever_Auth(t *testing.T) {
+	svc, _ := newFeverTestService(t)
+
+	resp := feverRequest(t, svc, "", url.Values{"api_key": {"wrong"}})
+	if resp.APIVersion != feverAPIVersion || resp.Auth != 0 {
+		t.Fatal("expected auth to fail with the wrong key", resp)
+	}
+
+	resp = feverRequest(t, svc, "", nil)
+	if resp.Auth != 1 {
+		t.Fatal("expected auth to succeed", resp)
+	}
+
+	svc.feeds.Config.Sync = nil
+
+	resp = feverRequest(t, svc, "", nil)
+	if resp.Auth != 0 {
+		t.Fatal("expected auth to fail when sync is not configured", resp)
+	}
+}
+
+func TestFever_GroupsFeedsItems(t *testing.T) {
+	svc, f := newFeverTestService(t)
+
+	resp := feverRequest(t, svc, "groups&feeds&items", nil)
+
+	if len(resp.Groups) != 1 || resp.Groups[0].Title != "Cat" {
+		t.Fatal("unexpected groups", resp.Groups)
+	}
+
+	if len(resp.Feeds) != 1 || resp.Feeds[0].Title != "API Feed" || resp.Feeds[0].URL != f.URL {
+		t.Fatal("unexpected feeds", resp.Feeds)
+	}
+
+	if len(resp.FeedsGroups) != 1 || resp.FeedsGroups[0].FeedIDs != strconv.FormatInt(resp.Feeds[0].ID, 10) {
+		t.Fatal("unexpected feeds_groups", resp.FeedsGroups)
+	}
+
+	if len(resp.Items) != 2 || resp.TotalItems != 2 || resp.Items[0].FeedID != resp.Feeds[0].ID {
+		t.Fatal("unexpected items", resp.Items)
+	}
+
+	// ids must be stable between requests
+	again := feverRequest(t, svc, "items", nil)
+	if again.Items[0].ID != resp.Items[0].ID {
+		t.Fatal("expected stable item ids")
+	}
+
+	since := feverRequest(t, svc, "items&since_id="+strconv.FormatInt(resp.Items[0].ID, 10), nil)
+	if len(since.Items) != 1 || since.Items[0].ID != resp.Items[1].ID {
+		t.Fatal("unexpected since_id items", since.Items)
+	}
+
+	maxID := feverRequest(t, svc, "items&max_id="+strconv.FormatInt(resp.Items[1].ID, 10), nil)
+	if len(maxID.Items) != 1 || maxID.Items[0].ID != resp.Items[0].ID {
+		t.Fatal("unexpected max_id items", maxID.Items)
+	}
+
+	withIDs := feverRequest(t, svc, "items&with_ids="+strconv.FormatInt(resp.Items[1].ID, 10), nil)
+	if len(withIDs.Items) != 1 || withIDs.Items[0].Title != resp.Items[1].Title {
+		t.Fatal("unexpected with_ids items", withIDs.Items)
+	}
+}
+
+func TestFever_Mark(t *testing.T) {
+	svc, f := newFeverTestService(t)
+
+	resp := feverRequest(t, svc, "items&unread_item_ids", nil)
+	first := strconv.FormatInt(resp.Items[0].ID, 10)
+
+	if len(strings.Split(resp.UnreadItemIDs, ",")) != 2 {
+		t.Fatal("expected 2 unread items", resp.UnreadItemIDs)
+	}
+
+	resp = feverRequest(t, svc, "", url.Values{"mark": {"item"}, "as": {"read"}, "id": {first}})
+	if resp.UnreadItemIDs == "" || strings.Contains(","+resp.UnreadItemIDs+",", ","+first+",") {
+		t.Fatal("expected item to be marked read", resp.UnreadItemIDs)
+	}
+
+	if f.UnreadItemCount() != 1 {
+		t.Fatal("expected the feed to have 1 unread item")
+	}
+
+	resp = feverRequest(t, svc, "", url.Values{"mark": {"item"}, "as": {"unread"}, "id": {first}})
+	if len(strings.Split(resp.UnreadItemIDs, ",")) != 2 {
+		t.Fatal("expected item to be marked unread", resp.UnreadItemIDs)
+	}
+
+	resp = feverRequest(t, svc, "", url.Values{"mark": {"item"}, "as": {"saved"}, "id": {first}})
+	if resp.SavedItemIDs != first {
+		t.Fatal("expected item to be saved", resp.SavedItemIDs)
+	}
+
+	resp = feverRequest(t, svc, "", url.Values{"mark": {"item"}, "as": {"unsaved"}, "id": {first}})
+	if resp.SavedItemIDs != "" {
+		t.Fatal("expected item to be unsaved", resp.SavedItemIDs)
+	}
+
+	resp = feverRequest(t, svc, "", url.Values{"mark": {"group"}, "as": {"read"}, "id": {"0"}})
+	if resp.UnreadItemIDs != "" {
+		t.Fatal("expected everything to be marked read", resp.UnreadItemIDs)
+	}
+
+	if f.UnreadItemCount() != 0 {
+		t.Fatal("expected the feed to have no unread items")
+	}
+}
+
+func TestFever_MarkUnknownGroup(t *testing.T) {
+	svc, f := newFeverTestService(t)
+
+	f.mu.Lock()
+	f.Category = ""
+	f.mu.Unlock()
+
+	resp := feverRequest(t, svc, "", url.Values{"mark": {"group"}, "as": {"read"}, "id": {"999"}})
+	if len(strings.Split(resp.UnreadItemIDs, ",")) != 2 {
+		t.Fatal("expected an unknown group not to mark anything read", resp.UnreadItemIDs)
+	}
+
+	if f.UnreadItemCount() != 2 {
+		t.Fatal("expected the uncategorised feed to still have 2 unread items")
+	}
+}
+
+func TestFever_StartsFeedUpdates(t *testing.T) {
+	fetched := make(chan struct{}, 1)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		select {
+		case fetched <- struct{}{}:
+		default:
+		}
+
+		w.Header().Set("Content-Type", "application/rss+xml")
+		_, _ = w.Write([]byte(`<rss version="2.0"><channel><title>Fever Feed</title></channel></rss>`))
+	}))
+	defer ts.Close()
+
+	svc := NewService()
+	svc.ids.Filename = filepath.Join(t.TempDir(), "ids.json")
+	svc.feeds.UpdateTime = time.Hour
+	svc.feeds.Config.Sync = &SyncConfig{Username: "user", Password: "pass"}
+
+	f := &feed{URL: ts.URL}
+	f.Init()
+	svc.feeds.list.Add(f)
+
+	defer f.StopTickedUpdate()
+
+	feverRequest(t, svc, "", nil)
+
+	select {
+	case <-fetched:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a Fever request to start the feed updating")
+	}
+
+	if svc.IsIdle() {
+		t.Fatal("expected a Fever request to count as client activity")
+	}
+}
@@ -0,0 +1,151 @@
+package rssole
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Filter rules match items by title, author, category or description and
+// hide them, mark them read or highlight them. Global rules (in the config
+// section) apply to every feed, before the feed's own rules.
+
+const (
+	filterHide      = "hide"
+	filterMarkRead  = "mark_read"
+	filterHighlight = "highlight"
+)
+
+var (
+	ErrFilterUnknownField  = errors.New("unknown filter field")
+	ErrFilterUnknownAction = errors.New("unknown filter action")
+	ErrFilterNoMatch       = errors.New("filter needs a match or regex")
+)
+
+type filterRule struct {
+	Field  string `json:"field,omitempty"` // title, author, category or description, empty for any
+	Match  string `json:"match,omitempty"` // case insensitive substring
+	Regex  string `json:"regex,omitempty"` // used instead of match if given
+	Action string `json:"action"`          // hide, mark_read or highlight
+
+	re      *regexp.Regexp
+	reErr   error
+	onceReg sync.Once
+}
+
+// Validate checks the rule makes sense, including that the regex compiles.
+func (r *filterRule) Validate() error {
+	switch r.Field {
+	case "", "title", "author", "category", "description":
+	default:
+		return fmt.Errorf("%q - %w", r.Field, ErrFilterUnknownField)
+	}
+
+	switch r.Action {
+	case filterHide, filterMarkRead, filterHighlight:
+	default:
+		return fmt.Errorf("%q - %w", r.Action, ErrFilterUnknownAction)
+	}
+
+	if r.Match == "" && r.Regex == "" {
+		return ErrFilterNoMatch
+	}
+
+	return r.compile()
+}
+
+func (r *filterRule) compile() error {
+	r.onceReg.Do(func() {
+		if r.Regex != "" {
+			r.re, r.reErr = regexp.Compile(r.Regex)
+			if r.reErr != nil {
+				r.reErr = fmt.Errorf("filter regex %q - %w", r.Regex, r.reErr)
+			}
+		}
+	})
+
+	return r.reErr
+}
+
+// fieldValues returns the parts of the item the rule looks at.
+func (r *filterRule) fieldValues(i *wrappedItem) []string {
+	author := ""
+	if i.Author != nil {
+		author = i.Author.Name
+	}
+
+	switch r.Field {
+	case "title":
+		return []string{i.Title}
+	case "author":
+		return []string{author}
+	case "category":
+		return i.Categories
+	case "description":
+		return []string{i.Item.Description, i.Content}
+	}
+
+	return append([]string{i.Title, author, i.Item.Description, i.Content}, i.Categories...)
+}
+
+// Matches returns true if the item matches the rule. Invalid rules never match.
+func (r *filterRule) Matches(i *wrappedItem) bool {
+	if r.compile() != nil {
+		return false
+	}
+
+	for _, value := range r.fieldValues(i) {
+		if r.re != nil {
+			if r.re.MatchString(value) {
+				return true
+			}
+		} else if r.Match != "" && strings.Contains(strings.ToLower(value), strings.ToLower(r.Match)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filterRules returns the global rules followed by the feed's own.
+func (f *feed) filterRules() []*filterRule {
+	rules := []*filterRule{}
+
+	if f.config != nil {
+		rules = append(rules, f.config.Filters...)
+	}
+
+	return append(rules, f.Filters...)
+}
+
+// applyFilters runs the filter rules over the item, returning false if it
+// should be hidden.
+func (f *feed) applyFilters(i *wrappedItem, rules []*filterRule) bool {
+	for _, rule := range rules {
+		if err := rule.compile(); err != nil {
+			f.log.Error("invalid filter", "error", err)
+
+			continue
+		}
+
+		if !rule.Matches(i) {
+			continue
+		}
+
+		switch rule.Action {
+		case filterHide:
+			return false
+		case filterMarkRead:
+			if i.IsUnread {
+				i.IsUnread = false
+				f.readCache.MarkRead(i.MarkReadID())
+			}
+		case filterHighlight:
+			i.IsHighlighted = true
+		}
+	}
+
+	return true
+}
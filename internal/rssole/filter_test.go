@@ -0,0 +1,138 @@
+package rssole
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func TestFilterRule_Validate(t *testing.T) {
+	tests := []struct {
+		rule *filterRule
+		err  error
+	}{
+		{&filterRule{Match: "x", Action: filterHide}, nil},
+		{&filterRule{Field: "title", Regex: "^x", Action: filterMarkRead}, nil},
+		{&filterRule{Field: "nope", Match: "x", Action: filterHide}, ErrFilterUnknownField},
+		{&filterRule{Match: "x", Action: "nope"}, ErrFilterUnknownAction},
+		{&filterRule{Action: filterHighlight}, ErrFilterNoMatch},
+	}
+
+	for _, tt := range tests {
+		if err := tt.rule.Validate(); !errors.Is(err, tt.err) {
+			t.Errorf("unexpected error for %+v: got %v want %v", tt.rule, err, tt.err)
+		}
+	}
+
+	if err := (&filterRule{Regex: "(", Action: filterHide}).Validate(); err == nil {
+		t.Error("expected an invalid regex to fail")
+	}
+}
+
+func TestFilterRule_Matches(t *testing.T) {
+	item := &wrappedItem{Item: &gofeed.Item{
+		Title:       "Bitcoin hits new high",
+		Author:      &gofeed.Person{Name: "Some Author"},
+		Categories:  []string{"crypto", "markets"},
+		Description: "<p>Line goes up</p>",
+	}}
+
+	tests := []struct {
+		rule    *filterRule
+		matches bool
+	}{
+		{&filterRule{Match: "bitcoin"}, true},
+		{&filterRule{Field: "title", Match: "BITCOIN"}, true},
+		{&filterRule{Field: "author", Match: "bitcoin"}, false},
+		{&filterRule{Field: "author", Match: "some author"}, true},
+		{&filterRule{Field: "category", Regex: "^crypto$"}, true},
+		{&filterRule{Field: "category", Regex: "^crypt$"}, false},
+		{&filterRule{Field: "description", Match: "goes up"}, true},
+		{&filterRule{Field: "title", Regex: "bitcoin"}, false}, // regexes are case sensitive
+		{&filterRule{Regex: "("}, false},
+	}
+
+	for _, tt := range tests {
+		if tt.rule.Matches(item) != tt.matches {
+			t.Errorf("unexpected match for %+v: want %v", tt.rule, tt.matches)
+		}
+	}
+}
+
+// filterTestReadCache records what was marked read.
+type filterTestReadCache struct {
+	mockReadCache
+	read []string
+}
+
+func (m *filterTestReadCache) MarkRead(id string) { m.read = append(m.read, id) }
+
+func TestUpdate_Filters(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8" ?>
+<rss version="2.0"><channel><title>Feed Title</title>
+<item><title>Keep me</title><link>http://keep.com/</link></item>
+<item><title>Sponsored: hide me</title><link>http://hide.com/</link></item>
+<item><title>Boring</title><link>http://boring.com/</link></item>
+<item><title>Exciting</title><link>http://exciting.com/</link></item>
+</channel></rss>`)
+	}))
+	defer ts.Close()
+
+	readCache := &filterTestReadCache{}
+
+	feed := &feed{
+		URL:       ts.URL,
+		readCache: readCache,
+		activity:  &mockActivityTracker{},
+		config: &ConfigSection{Filters: []*filterRule{
+			{Field: "title", Regex: "^Sponsored:", Action: filterHide},
+		}},
+		Filters: []*filterRule{
+			{Field: "title", Match: "boring", Action: filterMarkRead},
+			{Field: "title", Match: "exciting", Action: filterHighlight},
+		},
+	}
+	feed.Init()
+
+	if err := feed.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	items := map[string]*wrappedItem{}
+	for _, i := range feed.Items() {
+		items[i.Title] = i
+	}
+
+	if len(items) != 3 || items["Sponsored: hide me"] != nil {
+		t.Fatal("expected the global filter to hide an item", items)
+	}
+
+	if items["Boring"].IsUnread || len(readCache.read) != 1 || readCache.read[0] != "http://boring.com/" {
+		t.Fatal("expected the boring item to be marked read", readCache.read)
+	}
+
+	if !items["Exciting"].IsHighlighted || items["Keep me"].IsHighlighted {
+		t.Fatal("expected only the exciting item to be highlighted")
+	}
+}
+
+func TestReadFeedsFile_InvalidFilter(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "rssole.json")
+
+	err := os.WriteFile(filename, []byte(`{"config":{"filters":[{"regex":"(","action":"hide"}]},"feeds":[]}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := feeds{}
+	if err := f.readFeedsFile(filename); err == nil {
+		t.Fatal("expected an invalid filter to fail loading")
+	}
+}
@@ -0,0 +1,190 @@
+package rssole
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-shiori/go-readability"
+	"github.com/mmcdole/gofeed"
+)
+
+// Feeds with full_content set fetch each item's link and extract the main
+// article from the page, for feeds that only carry a headline or a line of
+// description. The article goes in the item's content, so it's sanitised
+// by Description() and kept by the archive like any other content.
+
+const (
+	fullContentMaxBody     = 5 << 20
+	fullContentConcurrency = 4
+	fullContentMaxFetches  = 30                 // per update, the rest are fetched next time
+	fullContentKeep        = 7 * 24 * time.Hour // articles not seen in a feed for this long are forgotten
+)
+
+var (
+	ErrFullContentNotHTML   = errors.New("not an html page")
+	ErrFullContentNoArticle = errors.New("unable to extract article")
+)
+
+type cachedArticle struct {
+	content string // empty if it can never be extracted, so it isn't tried again
+	seen    time.Time
+}
+
+// articleCache holds extracted articles by link, shared by the feeds (see
+// feeds.attach).
+type articleCache struct {
+	articles map[string]*cachedArticle
+	mu       sync.Mutex
+}
+
+func newArticleCache() *articleCache {
+	return &articleCache{articles: map[string]*cachedArticle{}}
+}
+
+func (c *articleCache) get(link string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	article, found := c.articles[link]
+	if !found {
+		return "", false
+	}
+
+	article.seen = time.Now()
+
+	return article.content, true
+}
+
+func (c *articleCache) put(link, content string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	for l, article := range c.articles {
+		if now.Sub(article.seen) > fullContentKeep {
+			delete(c.articles, l)
+		}
+	}
+
+	c.articles[link] = &cachedArticle{content: content, seen: now}
+}
+
+// fetchArticle gets the page and extracts its main content as html.
+func fetchArticle(link string) (string, error) {
+	pageURL, err := url.Parse(link)
+	if err != nil {
+		return "", fmt.Errorf("bad link %s - %w", link, err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot create new request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "rssole/"+Version)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", gofeed.HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		}
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return "", fmt.Errorf("%s is %q - %w", link, mediaType, ErrFullContentNotHTML)
+	}
+
+	article, err := readability.FromReader(io.LimitReader(resp.Body, fullContentMaxBody), resp.Request.URL)
+	if err != nil {
+		return "", fmt.Errorf("%w from %s - %w", ErrFullContentNoArticle, pageURL, err)
+	}
+
+	return article.Content, nil
+}
+
+// withFullContent returns a copy of the feed with the extracted articles as
+// each item's content. The feed itself may be shared with other feeds in a
+// pool, so it's left alone.
+func (f *feed) withFullContent(parsed *gofeed.Feed) *gofeed.Feed {
+	f.mu.RLock()
+	enabled := f.FullContent
+	f.mu.RUnlock()
+
+	if !enabled {
+		return parsed
+	}
+
+	// fetch what isn't cached yet, newest items first as feeds usually are
+	toFetch := []string{}
+
+	for _, item := range parsed.Items {
+		if item.Link == "" {
+			continue
+		}
+
+		if _, found := f.articles.get(item.Link); !found && len(toFetch) < fullContentMaxFetches {
+			toFetch = append(toFetch, item.Link)
+		}
+	}
+
+	if len(toFetch) > 0 {
+		f.log.Info("Fetching full content", "count", len(toFetch))
+	}
+
+	var wg sync.WaitGroup
+
+	sem := make(chan struct{}, fullContentConcurrency)
+
+	for _, link := range toFetch {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			content, err := fetchArticle(link)
+			<-sem
+
+			if err != nil {
+				f.log.Warn("Full content failed, using the feed's description", "link", link, "error", err)
+
+				// only remember failures that trying again won't fix
+				if !errors.Is(err, ErrFullContentNotHTML) && !errors.Is(err, ErrFullContentNoArticle) {
+					return
+				}
+			}
+
+			f.articles.put(link, content)
+		}()
+	}
+
+	wg.Wait()
+
+	withContent := *parsed
+	withContent.Items = make([]*gofeed.Item, 0, len(parsed.Items))
+
+	for _, item := range parsed.Items {
+		if content, _ := f.articles.get(item.Link); content != "" && item.Link != "" {
+			itemCopy := *item
+			itemCopy.Content = content
+			item = &itemCopy
+		}
+
+		withContent.Items = append(withContent.Items, item)
+	}
+
+	return &withContent
+}
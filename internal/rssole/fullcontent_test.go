@@ -0,0 +1,179 @@
+package rssole
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const fullContentTestArticle = `<html><head><title>Article</title></head><body>
+<nav><a href="/">Home</a> <a href="/news">News</a></nav>
+<article>
+<h1>The Whole Story</h1>
+<p>This is the first paragraph of the whole story, which goes on for quite a while so that it looks like real content worth reading.</p>
+<p>This is the second paragraph, with even more words in it, because readability wants a decent amount of text before it believes this is the article.</p>
+<p>And a third paragraph to round it off, mentioning the unmistakable phrase purple elephants so the test can find it.</p>
+</article>
+<footer>Copyright nobody</footer>
+</body></html>`
+
+func newFullContentTestServer(t *testing.T, articleFetches *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+
+	var ts *httptest.Server
+
+	mux.HandleFunc("GET /feed", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8" ?>
+<rss version="2.0">
+<channel>
+  <title>Headlines</title>
+  <item><title>Story</title><link>`+ts.URL+`/article</link><description>Just a headline</description></item>
+  <item><title>Gone</title><link>`+ts.URL+`/missing</link><description>Still here</description></item>
+</channel>
+</rss>`)
+	})
+	mux.HandleFunc("GET /article", func(w http.ResponseWriter, _ *http.Request) {
+		articleFetches.Add(1)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, fullContentTestArticle)
+	})
+
+	ts = httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestUpdate_FullContent(t *testing.T) {
+	mockRC, mockAT, teardown := feedSetUpTearDown(t)
+	defer teardown(t)
+
+	var articleFetches atomic.Int32
+
+	ts := newFullContentTestServer(t, &articleFetches)
+
+	fd := &feed{
+		URL:         ts.URL + "/feed",
+		FullContent: true,
+		readCache:   mockRC,
+		activity:    mockAT,
+	}
+	fd.Init()
+
+	if err := fd.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	descriptions := map[string]string{}
+	for _, item := range fd.Items() {
+		descriptions[item.Title] = item.Description()
+	}
+
+	if !strings.Contains(descriptions["Story"], "purple elephants") || strings.Contains(descriptions["Story"], "Copyright") {
+		t.Fatal("expected the article without the page around it, got", descriptions["Story"])
+	}
+
+	if !strings.Contains(descriptions["Gone"], "Still here") {
+		t.Fatal("expected a failed article to fall back to the description, got", descriptions["Gone"])
+	}
+
+	// articles are cached between updates
+	if err := fd.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	if articleFetches.Load() != 1 {
+		t.Fatal("expected the article to be fetched once, got", articleFetches.Load())
+	}
+}
+
+func TestUpdate_FullContentRetriesTransientFailures(t *testing.T) {
+	mockRC, mockAT, teardown := feedSetUpTearDown(t)
+	defer teardown(t)
+
+	var (
+		articleFetches atomic.Int32
+		ts             *httptest.Server
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /feed", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8" ?>
+<rss version="2.0"><channel><title>Headlines</title>
+  <item><title>Story</title><link>`+ts.URL+`/article</link><description>Just a headline</description></item>
+</channel></rss>`)
+	})
+	mux.HandleFunc("GET /article", func(w http.ResponseWriter, _ *http.Request) {
+		if articleFetches.Add(1) == 1 {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, fullContentTestArticle)
+	})
+
+	ts = httptest.NewServer(mux)
+	defer ts.Close()
+
+	fd := &feed{
+		URL:         ts.URL + "/feed",
+		FullContent: true,
+		readCache:   mockRC,
+		activity:    mockAT,
+	}
+	fd.Init()
+
+	for range 2 {
+		if err := fd.Update(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if articleFetches.Load() != 2 || !strings.Contains(fd.Items()[0].Description(), "purple elephants") {
+		t.Fatal("expected the article to be fetched again after a 503, fetches", articleFetches.Load())
+	}
+}
+
+func TestUpdate_FullContentOff(t *testing.T) {
+	mockRC, mockAT, teardown := feedSetUpTearDown(t)
+	defer teardown(t)
+
+	var articleFetches atomic.Int32
+
+	ts := newFullContentTestServer(t, &articleFetches)
+
+	fd := &feed{
+		URL:       ts.URL + "/feed",
+		readCache: mockRC,
+		activity:  mockAT,
+	}
+	fd.Init()
+
+	if err := fd.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	if articleFetches.Load() != 0 {
+		t.Fatal("expected no articles to be fetched")
+	}
+}
+
+func TestFetchArticle_NotHTML(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		fmt.Fprint(w, "%PDF-1.4")
+	}))
+	defer ts.Close()
+
+	if _, err := fetchArticle(ts.URL); !errors.Is(err, ErrFullContentNotHTML) {
+		t.Fatal("expected a not html error, got", err)
+	}
+}
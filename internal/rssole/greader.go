@@ -0,0 +1,619 @@
+package rssole
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// The Google Reader API (as implemented by FreshRSS) is the other common
+// sync protocol, spoken by NetNewsWire, FeedMe, ReadYou and friends. Only
+// the subset needed for reading is supported, subscriptions are still
+// managed in rssole. Categories are labels and starred items are starred.
+
+const (
+	greaderPrefix       = "/greader"
+	greaderAPIPrefix    = greaderPrefix + "/reader/api/0"
+	greaderReadingList  = "user/-/state/com.google/reading-list"
+	greaderRead         = "user/-/state/com.google/read"
+	greaderStarred      = "user/-/state/com.google/starred"
+	greaderLabelPrefix  = "user/-/label/"
+	greaderFeedPrefix   = "feed/"
+	greaderItemPrefix   = "tag:google.com,2005:reader/item/"
+	greaderDefaultItems = 20
+	greaderMaxItems     = 1000
+)
+
+func (s *Service) registerGReaderRoutes(mux *http.ServeMux) {
+	mux.HandleFunc(greaderPrefix+"/accounts/ClientLogin", s.greaderClientLogin)
+	mux.HandleFunc("GET "+greaderAPIPrefix+"/token", s.greaderAuth(s.greaderToken))
+	mux.HandleFunc("GET "+greaderAPIPrefix+"/user-info", s.greaderAuth(s.greaderUserInfo))
+	mux.HandleFunc("GET "+greaderAPIPrefix+"/subscription/list", s.greaderAuth(s.greaderSubscriptionList))
+	mux.HandleFunc("GET "+greaderAPIPrefix+"/tag/list", s.greaderAuth(s.greaderTagList))
+	mux.HandleFunc("GET "+greaderAPIPrefix+"/unread-count", s.greaderAuth(s.greaderUnreadCount))
+	mux.HandleFunc("GET "+greaderAPIPrefix+"/stream/contents/{stream...}", s.greaderAuth(s.greaderStreamContents))
+	mux.HandleFunc("GET "+greaderAPIPrefix+"/stream/items/ids", s.greaderAuth(s.greaderStreamItemIDs))
+	mux.HandleFunc(greaderAPIPrefix+"/stream/items/contents", s.greaderAuth(s.greaderStreamItemContents))
+	mux.HandleFunc("POST "+greaderAPIPrefix+"/edit-tag", s.greaderAuth(s.greaderEditTag))
+	mux.HandleFunc("POST "+greaderAPIPrefix+"/mark-all-as-read", s.greaderAuth(s.greaderMarkAllAsRead))
+}
+
+type greaderSubscription struct {
+	ID         string            `json:"id"`
+	Title      string            `json:"title"`
+	Categories []greaderCategory `json:"categories"`
+	URL        string            `json:"url"`
+	HTMLURL    string            `json:"htmlUrl"`
+	IconURL    string            `json:"iconUrl"`
+}
+
+type greaderCategory struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+type greaderTag struct {
+	ID   string `json:"id"`
+	Type string `json:"type,omitempty"`
+}
+
+type greaderUnreadCount struct {
+	ID                      string `json:"id"`
+	Count                   int    `json:"count"`
+	NewestItemTimestampUsec string `json:"newestItemTimestampUsec"`
+}
+
+type greaderLink struct {
+	Href string `json:"href"`
+	Type string `json:"type,omitempty"`
+}
+
+type greaderContent struct {
+	Content string `json:"content"`
+}
+
+type greaderOrigin struct {
+	StreamID string `json:"streamId"`
+	Title    string `json:"title"`
+	HTMLURL  string `json:"htmlUrl"`
+}
+
+type greaderItem struct {
+	ID            string         `json:"id"`
+	CrawlTimeMsec string         `json:"crawlTimeMsec"`
+	TimestampUsec string         `json:"timestampUsec"`
+	Published     int64          `json:"published"`
+	Title         string         `json:"title"`
+	Author        string         `json:"author,omitempty"`
+	Canonical     []greaderLink  `json:"canonical"`
+	Alternate     []greaderLink  `json:"alternate"`
+	Summary       greaderContent `json:"summary"`
+	Categories    []string       `json:"categories"`
+	Origin        greaderOrigin  `json:"origin"`
+}
+
+type greaderStream struct {
+	ID           string        `json:"id"`
+	Updated      int64         `json:"updated"`
+	Items        []greaderItem `json:"items"`
+	Continuation string        `json:"continuation,omitempty"`
+}
+
+type greaderItemRef struct {
+	ID              string   `json:"id"`
+	DirectStreamIDs []string `json:"directStreamIds"`
+	TimestampUsec   string   `json:"timestampUsec"`
+}
+
+type greaderItemRefs struct {
+	ItemRefs     []greaderItemRef `json:"itemRefs"`
+	Continuation string           `json:"continuation,omitempty"`
+}
+
+// greaderTokens holds the tokens handed out by ClientLogin. Like web
+// sessions they're only kept in memory, so a restart means logging in again.
+type greaderTokens struct {
+	tokens map[string]time.Time // token -> expiry
+	mu     sync.Mutex
+}
+
+// Create returns a new random token for the user.
+func (g *greaderTokens) Create(username string) (string, error) {
+	buf := make([]byte, sessionTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error creating greader token - %w", err)
+	}
+
+	token := username + "/" + hex.EncodeToString(buf)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.tokens == nil {
+		g.tokens = map[string]time.Time{}
+	}
+
+	// a good time to forget old tokens
+	for t, expires := range g.tokens {
+		if time.Now().After(expires) {
+			delete(g.tokens, t)
+		}
+	}
+
+	g.tokens[token] = time.Now().Add(sessionLifetime)
+
+	return token, nil
+}
+
+// Valid returns true if the token was handed out and hasn't expired.
+func (g *greaderTokens) Valid(given string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for token, expires := range g.tokens {
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1 {
+			return time.Now().Before(expires)
+		}
+	}
+
+	return false
+}
+
+func (s *Service) greaderClientLogin(w http.ResponseWriter, req *http.Request) {
+	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
+
+	if err := req.ParseForm(); err != nil {
+		logger.Error("ParseForm", "error", err)
+	}
+
+	if !s.greaderCredentialsValid(req.FormValue("Email"), req.FormValue("Passwd")) {
+		http.Error(w, "Error=BadAuthentication", http.StatusUnauthorized)
+
+		return
+	}
+
+	token, err := s.greaderTokens.Create(s.feeds.Config.Sync.Username)
+	if err != nil {
+		logger.Error("greaderTokens.Create", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "SID=%s\nLSID=%s\nAuth=%s\n", token, token, token)
+}
+
+func (s *Service) greaderCredentialsValid(username, password string) bool {
+	sync := s.feeds.Config.Sync
+
+	return sync != nil && sync.Username != "" &&
+		subtle.ConstantTimeCompare([]byte(username), []byte(sync.Username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(sync.Password)) == 1
+}
+
+func greaderRequestToken(req *http.Request) string {
+	return strings.TrimPrefix(req.Header.Get("Authorization"), "GoogleLogin auth=")
+}
+
+func (s *Service) greaderTokenValid(req *http.Request) bool {
+	sync := s.feeds.Config.Sync
+
+	return sync != nil && sync.Username != "" && s.greaderTokens.Valid(greaderRequestToken(req))
+}
+
+// greaderAuth only lets requests with a valid "GoogleLogin auth=" header
+// through. A sync app may be the only client, so they count as activity.
+func (s *Service) greaderAuth(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !s.greaderTokenValid(req) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+			return
+		}
+
+		s.recordActivity()
+		h(w, req)
+	}
+}
+
+// greaderToken hands back the client's own token for edits.
+func (s *Service) greaderToken(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, greaderRequestToken(req))
+}
+
+func (s *Service) greaderUserInfo(w http.ResponseWriter, _ *http.Request) {
+	sync := s.feeds.Config.Sync
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"userId":        sync.Username,
+		"userName":      sync.Username,
+		"userProfileId": sync.Username,
+		"userEmail":     "",
+	})
+}
+
+func greaderLabel(category string) string {
+	return greaderLabelPrefix + category
+}
+
+func (s *Service) greaderSubscriptionList(w http.ResponseWriter, _ *http.Request) {
+	subs := []greaderSubscription{}
+
+	for _, f := range s.feeds.All() {
+		f.mu.RLock()
+
+		sub := greaderSubscription{
+			ID:         greaderFeedPrefix + f.URL,
+			Title:      f.Title(),
+			Categories: []greaderCategory{},
+			URL:        f.URL,
+			HTMLURL:    f.Link(),
+		}
+
+		if f.Category != "" {
+			sub.Categories = append(sub.Categories, greaderCategory{ID: greaderLabel(f.Category), Label: f.Category})
+		}
+
+		f.mu.RUnlock()
+
+		subs = append(subs, sub)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"subscriptions": subs})
+}
+
+func (s *Service) greaderTagList(w http.ResponseWriter, _ *http.Request) {
+	tags := []greaderTag{{ID: greaderStarred}}
+
+	categories := []string{}
+
+	for category := range s.feeds.FeedTree() {
+		if category != "" {
+			categories = append(categories, category)
+		}
+	}
+
+	sort.Strings(categories)
+
+	for _, category := range categories {
+		tags = append(tags, greaderTag{ID: greaderLabel(category), Type: "folder"})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"tags": tags})
+}
+
+func timestampUsec(t time.Time) string {
+	return strconv.FormatInt(t.UnixMicro(), 10)
+}
+
+func (s *Service) greaderUnreadCount(w http.ResponseWriter, _ *http.Request) {
+	idx := s.numericIndex()
+
+	counts := map[string]*greaderUnreadCount{}
+	newest := map[string]time.Time{}
+	order := []string{}
+
+	count := func(id string, ref *numericRef) {
+		c, found := counts[id]
+		if !found {
+			c = &greaderUnreadCount{ID: id}
+			counts[id] = c
+			order = append(order, id)
+		}
+
+		c.Count++
+
+		if t := itemTime(ref.Item); t.After(newest[id]) {
+			newest[id] = t
+		}
+	}
+
+	for _, ref := range idx.refs {
+		if ref.Feed == nil || !s.readLut.IsUnread(ref.Item.MarkReadID()) {
+			continue
+		}
+
+		count(greaderReadingList, ref)
+		count(greaderFeedPrefix+ref.Feed.URL, ref)
+
+		if ref.Feed.Category != "" {
+			count(greaderLabel(ref.Feed.Category), ref)
+		}
+	}
+
+	unreadCounts := []greaderUnreadCount{}
+	maxCount := 0
+
+	for _, id := range order {
+		counts[id].NewestItemTimestampUsec = timestampUsec(newest[id])
+		unreadCounts = append(unreadCounts, *counts[id])
+		maxCount = max(maxCount, counts[id].Count)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"max":          maxCount,
+		"unreadcounts": unreadCounts,
+	})
+}
+
+// parseGReaderItemID accepts the long form (hex) and short form (decimal) item ids.
+func parseGReaderItemID(raw string) (int64, bool) {
+	if hexID, found := strings.CutPrefix(raw, greaderItemPrefix); found {
+		id, err := strconv.ParseUint(hexID, 16, 64)
+
+		return int64(id), err == nil //nolint:gosec // ids are always positive
+	}
+
+	id, err := strconv.ParseInt(raw, 10, 64)
+
+	return id, err == nil
+}
+
+func greaderLongItemID(id int64) string {
+	return fmt.Sprintf("%s%016x", greaderItemPrefix, id)
+}
+
+// inStream returns true if the item belongs to the stream (a feed, label or state).
+func (s *Service) inStream(ref *numericRef, stream string) bool {
+	switch {
+	case stream == greaderReadingList:
+		return ref.Feed != nil
+	case stream == greaderStarred:
+		return s.stars.IsStarred(ref.Item.ID())
+	case stream == greaderRead:
+		return !s.readLut.IsUnread(ref.Item.MarkReadID())
+	case strings.HasPrefix(stream, greaderFeedPrefix):
+		return ref.Item.Feed.URL == strings.TrimPrefix(stream, greaderFeedPrefix)
+	case strings.HasPrefix(stream, greaderLabelPrefix):
+		return ref.Feed != nil && ref.Feed.Category == strings.TrimPrefix(stream, greaderLabelPrefix)
+	}
+
+	return false
+}
+
+// greaderFilter applies the common stream parameters (s, xt, it, ot, nt, r, n, c)
+// and returns the page of items asked for along with the continuation, if any.
+func (s *Service) greaderFilter(req *http.Request, stream string, idx *numericIndex) ([]*numericRef, string) {
+	exclude := req.FormValue("xt")
+	include := req.FormValue("it")
+
+	var olderThan, newerThan time.Time
+
+	if ot, err := strconv.ParseInt(req.FormValue("ot"), 10, 64); err == nil {
+		olderThan = time.Unix(ot, 0)
+	}
+
+	if nt, err := strconv.ParseInt(req.FormValue("nt"), 10, 64); err == nil {
+		newerThan = time.Unix(nt, 0)
+	}
+
+	refs := []*numericRef{}
+
+	for _, ref := range idx.refs {
+		t := itemTime(ref.Item)
+
+		if !s.inStream(ref, stream) ||
+			(exclude != "" && s.inStream(ref, exclude)) ||
+			(include != "" && !s.inStream(ref, include)) ||
+			(!olderThan.IsZero() && !t.Before(olderThan)) ||
+			(!newerThan.IsZero() && !t.After(newerThan)) {
+			continue
+		}
+
+		refs = append(refs, ref)
+	}
+
+	// newest first unless asked for oldest first
+	if req.FormValue("r") != "o" {
+		sort.SliceStable(refs, func(a, b int) bool { return refs[a].ID > refs[b].ID })
+	}
+
+	n, err := strconv.Atoi(req.FormValue("n"))
+	if err != nil || n <= 0 {
+		n = greaderDefaultItems
+	}
+
+	n = min(n, greaderMaxItems)
+
+	offset, _ := strconv.Atoi(req.FormValue("c"))
+	offset = min(max(offset, 0), len(refs))
+
+	end := min(offset+n, len(refs))
+
+	continuation := ""
+	if end < len(refs) {
+		continuation = strconv.Itoa(end)
+	}
+
+	return refs[offset:end], continuation
+}
+
+func (s *Service) greaderItem(ref *numericRef) greaderItem {
+	t := itemTime(ref.Item)
+
+	author := ""
+	if ref.Item.Author != nil {
+		author = ref.Item.Author.Name
+	}
+
+	categories := []string{greaderReadingList}
+
+	if !s.readLut.IsUnread(ref.Item.MarkReadID()) {
+		categories = append(categories, greaderRead)
+	}
+
+	if s.stars.IsStarred(ref.Item.ID()) {
+		categories = append(categories, greaderStarred)
+	}
+
+	if ref.Item.Feed.Category != "" {
+		categories = append(categories, greaderLabel(ref.Item.Feed.Category))
+	}
+
+	f := ref.Item.Feed
+
+	f.mu.RLock()
+	origin := greaderOrigin{
+		StreamID: greaderFeedPrefix + f.URL,
+		Title:    f.Title(),
+		HTMLURL:  f.Link(),
+	}
+	f.mu.RUnlock()
+
+	return greaderItem{
+		ID:            greaderLongItemID(ref.ID),
+		CrawlTimeMsec: strconv.FormatInt(t.UnixMilli(), 10),
+		TimestampUsec: timestampUsec(t),
+		Published:     t.Unix(),
+		Title:         ref.Item.Title,
+		Author:        author,
+		Canonical:     []greaderLink{{Href: ref.Item.Link}},
+		Alternate:     []greaderLink{{Href: ref.Item.Link, Type: "text/html"}},
+		Summary:       greaderContent{Content: ref.Item.Description()},
+		Categories:    categories,
+		Origin:        origin,
+	}
+}
+
+func (s *Service) writeGReaderStream(w http.ResponseWriter, stream string, refs []*numericRef, continuation string) {
+	items := make([]greaderItem, len(refs))
+	for i, ref := range refs {
+		items[i] = s.greaderItem(ref)
+	}
+
+	writeJSON(w, http.StatusOK, greaderStream{
+		ID:           stream,
+		Updated:      s.getLastmodified().Unix(),
+		Items:        items,
+		Continuation: continuation,
+	})
+}
+
+func (s *Service) greaderStreamContents(w http.ResponseWriter, req *http.Request) {
+	stream := req.PathValue("stream")
+	if stream == "" {
+		stream = greaderReadingList
+	}
+
+	refs, continuation := s.greaderFilter(req, stream, s.numericIndex())
+
+	s.writeGReaderStream(w, stream, refs, continuation)
+}
+
+func (s *Service) greaderStreamItemIDs(w http.ResponseWriter, req *http.Request) {
+	stream := req.FormValue("s")
+	if stream == "" {
+		stream = greaderReadingList
+	}
+
+	refs, continuation := s.greaderFilter(req, stream, s.numericIndex())
+
+	itemRefs := make([]greaderItemRef, len(refs))
+	for i, ref := range refs {
+		itemRefs[i] = greaderItemRef{
+			ID:              strconv.FormatInt(ref.ID, 10),
+			DirectStreamIDs: []string{},
+			TimestampUsec:   timestampUsec(itemTime(ref.Item)),
+		}
+	}
+
+	writeJSON(w, http.StatusOK, greaderItemRefs{ItemRefs: itemRefs, Continuation: continuation})
+}
+
+// greaderRefs returns the items named by the (repeated) i parameter.
+func greaderRefs(req *http.Request, idx *numericIndex) []*numericRef {
+	refs := []*numericRef{}
+
+	for _, raw := range req.Form["i"] {
+		if id, ok := parseGReaderItemID(raw); ok {
+			if ref, found := idx.byID[id]; found {
+				refs = append(refs, ref)
+			}
+		}
+	}
+
+	return refs
+}
+
+func (s *Service) greaderStreamItemContents(w http.ResponseWriter, req *http.Request) {
+	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
+
+	if err := req.ParseForm(); err != nil {
+		logger.Error("ParseForm", "error", err)
+	}
+
+	s.writeGReaderStream(w, greaderReadingList, greaderRefs(req, s.numericIndex()), "")
+}
+
+func writeOK(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, "OK")
+}
+
+func (s *Service) greaderEditTag(w http.ResponseWriter, req *http.Request) {
+	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
+
+	if err := req.ParseForm(); err != nil {
+		logger.Error("ParseForm", "error", err)
+	}
+
+	for _, ref := range greaderRefs(req, s.numericIndex()) {
+		for _, tag := range req.Form["a"] {
+			switch tag {
+			case greaderRead:
+				s.setRead(ref, true)
+			case greaderStarred:
+				s.stars.Star(ref.Item)
+			}
+		}
+
+		for _, tag := range req.Form["r"] {
+			switch tag {
+			case greaderRead:
+				s.setRead(ref, false)
+			case greaderStarred:
+				s.stars.Unstar(ref.Item.ID())
+			}
+		}
+	}
+
+	s.readLut.Persist()
+	s.stars.Persist()
+
+	writeOK(w)
+}
+
+func (s *Service) greaderMarkAllAsRead(w http.ResponseWriter, req *http.Request) {
+	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
+
+	if err := req.ParseForm(); err != nil {
+		logger.Error("ParseForm", "error", err)
+	}
+
+	// only mark what the client had seen at the time
+	before := time.Now()
+	if ts, err := strconv.ParseInt(req.FormValue("ts"), 10, 64); err == nil {
+		before = time.UnixMicro(ts)
+	}
+
+	for _, ref := range s.numericIndex().refs {
+		if ref.Feed != nil && s.inStream(ref, req.FormValue("s")) && !itemTime(ref.Item).After(before) {
+			s.setRead(ref, true)
+		}
+	}
+
+	s.readLut.Persist()
+
+	writeOK(w)
+}
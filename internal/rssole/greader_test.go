@@ -0,0 +1,197 @@
+package rssole
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const greaderTestToken = "user/test"
+
+func newGReaderTestService(t *testing.T) (*Service, *feed, *http.ServeMux) {
+	t.Helper()
+
+	svc, f, _ := newAPITestService(t)
+	svc.feeds.Config.Sync = &SyncConfig{Username: "user", Password: "pass"}
+	svc.greaderTokens.tokens = map[string]time.Time{greaderTestToken: time.Now().Add(time.Hour)}
+
+	mux := http.NewServeMux()
+	svc.registerGReaderRoutes(mux)
+
+	return svc, f, mux
+}
+
+func greaderRequest(t *testing.T, mux *http.ServeMux, method, path string, form url.Values, expectedStatus int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(method, path, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "GoogleLogin auth="+greaderTestToken)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != expectedStatus {
+		t.Fatalf("%s %s returned wrong status code: got %v want %v (%s)",
+			method, path, rr.Code, expectedStatus, rr.Body.String())
+	}
+
+	return rr
+}
+
+func greaderStreamRequest(t *testing.T, mux *http.ServeMux, path string) greaderStream {
+	t.Helper()
+
+	var stream greaderStream
+
+	rr := greaderRequest(t, mux, http.MethodGet, path, url.Values{}, http.StatusOK)
+	if err := json.Unmarshal(rr.Body.Bytes(), &stream); err != nil {
+		t.Fatal(err)
+	}
+
+	return stream
+}
+
+func TestGReader_ClientLogin(t *testing.T) {
+	_, _, mux := newGReaderTestService(t)
+
+	rr := greaderRequest(t, mux, http.MethodPost, "/greader/accounts/ClientLogin",
+		url.Values{"Email": {"user"}, "Passwd": {"pass"}}, http.StatusOK)
+
+	_, token, found := strings.Cut(rr.Body.String(), "Auth=")
+	if !found || !strings.HasPrefix(token, "user/") {
+		t.Fatal("expected an auth token", rr.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/greader/reader/api/0/token", nil)
+	req.Header.Set("Authorization", "GoogleLogin auth="+strings.TrimSpace(token))
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != strings.TrimSpace(token) {
+		t.Fatal("expected the issued token to be accepted", rr.Code, rr.Body.String())
+	}
+
+	rr = greaderRequest(t, mux, http.MethodPost, "/greader/accounts/ClientLogin",
+		url.Values{"Email": {"user"}, "Passwd": {"pass"}}, http.StatusOK)
+	if strings.Contains(rr.Body.String(), token) {
+		t.Fatal("expected each login to get a new token", rr.Body.String())
+	}
+
+	greaderRequest(t, mux, http.MethodPost, "/greader/accounts/ClientLogin",
+		url.Values{"Email": {"user"}, "Passwd": {"wrong"}}, http.StatusUnauthorized)
+
+	req = httptest.NewRequest(http.MethodGet, "/greader/reader/api/0/subscription/list", nil)
+	req.Header.Set("Authorization", "GoogleLogin auth=wrong")
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatal("expected a bad token to be unauthorized", rr.Code)
+	}
+}
+
+func TestGReader_TokenExpires(t *testing.T) {
+	svc, _, mux := newGReaderTestService(t)
+	svc.greaderTokens.tokens[greaderTestToken] = time.Now().Add(-time.Minute)
+
+	greaderRequest(t, mux, http.MethodGet, "/greader/reader/api/0/user-info", url.Values{}, http.StatusUnauthorized)
+}
+
+func TestGReader_RecordsActivity(t *testing.T) {
+	svc, _, mux := newGReaderTestService(t)
+
+	greaderRequest(t, mux, http.MethodGet, "/greader/reader/api/0/user-info", url.Values{}, http.StatusOK)
+
+	svc.lastActivityMu.Lock()
+	defer svc.lastActivityMu.Unlock()
+
+	if svc.lastActivity.IsZero() {
+		t.Fatal("expected a Google Reader request to count as client activity")
+	}
+}
+
+func TestGReader_SubscriptionsAndTags(t *testing.T) {
+	_, f, mux := newGReaderTestService(t)
+
+	rr := greaderRequest(t, mux, http.MethodGet, "/greader/reader/api/0/subscription/list?output=json", url.Values{}, http.StatusOK)
+	if !strings.Contains(rr.Body.String(), `"id":"feed/`+f.URL+`"`) ||
+		!strings.Contains(rr.Body.String(), `"label":"Cat"`) {
+		t.Fatal("unexpected subscriptions", rr.Body.String())
+	}
+
+	rr = greaderRequest(t, mux, http.MethodGet, "/greader/reader/api/0/tag/list", url.Values{}, http.StatusOK)
+	if !strings.Contains(rr.Body.String(), `"id":"user/-/label/Cat","type":"folder"`) {
+		t.Fatal("unexpected tags", rr.Body.String())
+	}
+
+	rr = greaderRequest(t, mux, http.MethodGet, "/greader/reader/api/0/unread-count", url.Values{}, http.StatusOK)
+	if !strings.Contains(rr.Body.String(), `"id":"user/-/label/Cat","count":2`) {
+		t.Fatal("unexpected unread counts", rr.Body.String())
+	}
+}
+
+func TestGReader_StreamsAndEditTag(t *testing.T) {
+	svc, f, mux := newGReaderTestService(t)
+
+	stream := greaderStreamRequest(t, mux, "/greader/reader/api/0/stream/contents/"+url.PathEscape("feed/"+f.URL)+"?n=1")
+	if len(stream.Items) != 1 || stream.Continuation != "1" || stream.Items[0].Origin.Title != "API Feed" {
+		t.Fatal("unexpected feed stream", stream)
+	}
+
+	stream = greaderStreamRequest(t, mux, "/greader/reader/api/0/stream/contents/user/-/label/Cat?c=1")
+	if len(stream.Items) != 1 || stream.Continuation != "" {
+		t.Fatal("unexpected label stream", stream)
+	}
+
+	var refs greaderItemRefs
+
+	rr := greaderRequest(t, mux, http.MethodGet, "/greader/reader/api/0/stream/items/ids?s=user/-/state/com.google/reading-list&xt=user/-/state/com.google/read", url.Values{}, http.StatusOK)
+	if err := json.Unmarshal(rr.Body.Bytes(), &refs); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(refs.ItemRefs) != 2 {
+		t.Fatal("expected 2 unread item ids", refs)
+	}
+
+	greaderRequest(t, mux, http.MethodPost, "/greader/reader/api/0/edit-tag",
+		url.Values{"i": {refs.ItemRefs[0].ID}, "a": {greaderRead, greaderStarred}}, http.StatusOK)
+
+	if f.UnreadItemCount() != 1 || svc.stars.Count() != 1 {
+		t.Fatal("expected the item to be read and starred")
+	}
+
+	stream = greaderStreamRequest(t, mux, "/greader/reader/api/0/stream/contents/user/-/state/com.google/starred")
+	if len(stream.Items) != 1 {
+		t.Fatal("expected 1 starred item", stream)
+	}
+
+	// long form ids work too
+	greaderRequest(t, mux, http.MethodPost, "/greader/reader/api/0/stream/items/contents",
+		url.Values{"i": {stream.Items[0].ID}}, http.StatusOK)
+
+	greaderRequest(t, mux, http.MethodPost, "/greader/reader/api/0/edit-tag",
+		url.Values{"i": {stream.Items[0].ID}, "r": {greaderRead}}, http.StatusOK)
+
+	if f.UnreadItemCount() != 2 {
+		t.Fatal("expected the item to be unread again")
+	}
+
+	greaderRequest(t, mux, http.MethodPost, "/greader/reader/api/0/mark-all-as-read",
+		url.Values{"s": {"user/-/label/Cat"}}, http.StatusOK)
+
+	if f.UnreadItemCount() != 0 {
+		t.Fatal("expected everything to be read")
+	}
+}
@@ -0,0 +1,417 @@
+package rssole
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/exp/slog"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Images in items are fetched through /img rather than straight from the
+// browser, so publishers (and their trackers) don't see who is reading,
+// and http images still show on an https rssole. Proxied urls are signed,
+// so the proxy only fetches images rssole itself put on a page. As those
+// urls are still picked by feed authors, it won't fetch from private
+// addresses either.
+
+const (
+	imageMaxBytes      = 10 << 20
+	imageCacheMaxBytes = 256 << 20
+	imageBrowserCache  = 7 * 24 * time.Hour
+)
+
+var (
+	ErrImageTooLarge       = errors.New("image is too large")
+	ErrImageNotImage       = errors.New("not an image")
+	ErrImagePrivateAddress = errors.New("refusing to fetch images from a private address")
+)
+
+type imageProxy struct {
+	Dir string // on disk cache, none if empty
+
+	key      []byte
+	client   *http.Client
+	maxBytes int64      // of the cache
+	mu       sync.Mutex // guards the cache
+}
+
+func newImageProxy() *imageProxy {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("unable to create image proxy key - %v", err))
+	}
+
+	return &imageProxy{key: key, client: newImageClient(), maxBytes: imageCacheMaxBytes}
+}
+
+// loadKey reads the signing key, saving the current one if there isn't one
+// yet, so proxied urls in open pages (and the service worker's cache) still
+// work after a restart.
+func (p *imageProxy) loadKey(filename string) {
+	if filename == "" {
+		return
+	}
+
+	data, err := os.ReadFile(filename)
+	switch {
+	case err == nil:
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err == nil && len(key) >= len(p.key) {
+			p.key = key
+
+			return
+		}
+
+		slog.Error("invalid image proxy key, replacing it", "filename", filename)
+	case !errors.Is(err, os.ErrNotExist):
+		slog.Error("ReadFile failed", "filename", filename, "error", err)
+
+		return
+	}
+
+	if err := os.WriteFile(filename, []byte(hex.EncodeToString(p.key)+"\n"), 0o600); err != nil {
+		slog.Error("error writefile", "filename", filename, "error", err)
+	}
+}
+
+// newImageClient verifies certificates (unlike the feed fetching, which
+// ignores cert errors) and refuses private addresses. The address is checked
+// as it's dialled, so redirects and DNS rebinding can't get around it.
+func newImageClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: httpClientTimeout,
+		Control: refusePrivateAddress,
+	}
+
+	return &http.Client{
+		Timeout: httpClientTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSClientConfig:     &tls.Config{MinVersion: tls.VersionTLS12},
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// Blocked on top of the private, loopback and link-local addresses.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier grade NAT
+}
+
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func refusePrivateAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%q - %w", address, err)
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%q - %w", address, err)
+	}
+
+	if !publicAddress(addr) {
+		return fmt.Errorf("%s - %w", addr, ErrImagePrivateAddress)
+	}
+
+	return nil
+}
+
+func (p *imageProxy) sign(imageURL string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(imageURL))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (p *imageProxy) validSignature(imageURL, signature string) bool {
+	return hmac.Equal([]byte(p.sign(imageURL)), []byte(signature))
+}
+
+// URL is the proxied url for an image, anything that isn't http(s) is
+// left alone.
+func (p *imageProxy) URL(imageURL string) string {
+	u, err := url.Parse(imageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return imageURL
+	}
+
+	return "/img?" + url.Values{"u": {imageURL}, "s": {p.sign(imageURL)}}.Encode()
+}
+
+// rewriteHTML points the images in (already sanitised) content at the proxy.
+func (p *imageProxy) rewriteHTML(content template.HTML) template.HTML {
+	context := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+
+	nodes, err := html.ParseFragment(strings.NewReader(string(content)), context)
+	if err != nil {
+		return content
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Img {
+			for idx, a := range n.Attr {
+				if a.Key == "src" {
+					n.Attr[idx].Val = p.URL(a.Val)
+				}
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+
+	var b bytes.Buffer
+
+	for _, n := range nodes {
+		walk(n)
+
+		if err := html.Render(&b, n); err != nil {
+			return content
+		}
+	}
+
+	return template.HTML(b.String()) //nolint:gosec // re-rendered from sanitised content
+}
+
+func (p *imageProxy) cacheFile(imageURL string) string {
+	hash := sha256.Sum256([]byte(imageURL))
+
+	return filepath.Join(p.Dir, hex.EncodeToString(hash[:]))
+}
+
+// cached returns the content type and image from the cache, if it's there.
+func (p *imageProxy) cached(imageURL string) (string, []byte, bool) {
+	if p.Dir == "" {
+		return "", nil, false
+	}
+
+	filename := p.cacheFile(imageURL)
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", nil, false
+	}
+
+	contentType, body, found := bytes.Cut(data, []byte("\n"))
+	if !found {
+		return "", nil, false
+	}
+
+	// the modification time is when it was last used
+	now := time.Now()
+	_ = os.Chtimes(filename, now, now)
+
+	return string(contentType), body, true
+}
+
+// store adds the image to the cache, dropping the least recently used
+// images if it's grown too large.
+func (p *imageProxy) store(imageURL, contentType string, body []byte) {
+	if p.Dir == "" {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := os.MkdirAll(p.Dir, 0o755); err != nil {
+		slog.Error("image cache", "error", err)
+
+		return
+	}
+
+	filename := p.cacheFile(imageURL)
+
+	tmp, err := os.CreateTemp(p.Dir, ".tmp-*")
+	if err != nil {
+		slog.Error("image cache", "error", err)
+
+		return
+	}
+
+	w := bufio.NewWriter(tmp)
+	_, _ = w.WriteString(contentType + "\n")
+	_, _ = w.Write(body)
+
+	if err := errors.Join(w.Flush(), tmp.Close()); err != nil {
+		slog.Error("image cache", "error", err)
+		os.Remove(tmp.Name())
+
+		return
+	}
+
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		slog.Error("image cache", "error", err)
+		os.Remove(tmp.Name())
+
+		return
+	}
+
+	p.evict()
+}
+
+// Caller must hold p.mu.
+func (p *imageProxy) evict() {
+	entries, err := os.ReadDir(p.Dir)
+	if err != nil {
+		return
+	}
+
+	files := []os.FileInfo{}
+	total := int64(0)
+
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+			files = append(files, info)
+			total += info.Size()
+		}
+	}
+
+	slices.SortFunc(files, func(a, b os.FileInfo) int {
+		return a.ModTime().Compare(b.ModTime())
+	})
+
+	for _, info := range files {
+		if total <= p.maxBytes {
+			break
+		}
+
+		if err := os.Remove(filepath.Join(p.Dir, info.Name())); err == nil {
+			total -= info.Size()
+		}
+	}
+}
+
+// imageContentType checks the image really is one, svgs aren't allowed as they
+// can carry script.
+func imageContentType(header string, body []byte) (string, error) {
+	mediaType, _, _ := mime.ParseMediaType(header)
+	sniffed := http.DetectContentType(body)
+
+	if !strings.HasPrefix(mediaType, "image/") || mediaType == "image/svg+xml" || !strings.HasPrefix(sniffed, "image/") {
+		return "", fmt.Errorf("%q (looks like %q) %w", mediaType, sniffed, ErrImageNotImage)
+	}
+
+	return sniffed, nil
+}
+
+func (p *imageProxy) fetch(imageURL string) (string, []byte, error) {
+	req, err := http.NewRequest(http.MethodGet, imageURL, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot create new request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "rssole/"+Version)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", nil, gofeed.HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, imageMaxBytes+1))
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to read body: %w", err)
+	}
+
+	if len(body) > imageMaxBytes {
+		return "", nil, ErrImageTooLarge
+	}
+
+	contentType, err := imageContentType(resp.Header.Get("Content-Type"), body)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return contentType, body, nil
+}
+
+func (s *Service) image(w http.ResponseWriter, req *http.Request) {
+	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
+
+	imageURL := req.URL.Query().Get("u")
+
+	if !s.images.validSignature(imageURL, req.URL.Query().Get("s")) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+
+		return
+	}
+
+	contentType, body, found := s.images.cached(imageURL)
+	if !found {
+		var err error
+
+		contentType, body, err = s.images.fetch(imageURL)
+		if err != nil {
+			logger.Info("image proxy", "url", imageURL, "error", err)
+			http.Error(w, "unable to fetch image", http.StatusBadGateway)
+
+			return
+		}
+
+		s.images.store(imageURL, contentType, body)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(imageBrowserCache.Seconds())))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+
+	if _, err := w.Write(body); err != nil {
+		logger.Error("image proxy write", "error", err)
+	}
+}
@@ -0,0 +1,292 @@
+package rssole
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01")
+
+func newImageTestServer(t *testing.T, fetches *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /image.png", func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(testPNG)
+	})
+	mux.HandleFunc("GET /pretend.png", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		fmt.Fprint(w, `<html><script>alert(1)</script></html>`)
+	})
+	mux.HandleFunc("GET /image.svg", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		fmt.Fprint(w, `<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"></svg>`)
+	})
+	mux.HandleFunc("GET /huge.png", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(testPNG)
+		_, _ = w.Write(make([]byte, imageMaxBytes))
+	})
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func imageRequest(t *testing.T, svc *Service, path string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(svc.image).ServeHTTP(rr, req)
+
+	return rr
+}
+
+func TestImageProxy_URL(t *testing.T) {
+	p := newImageProxy()
+
+	proxied, err := url.Parse(p.URL("http://example.com/a.png?w=100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if proxied.Path != "/img" || proxied.Query().Get("u") != "http://example.com/a.png?w=100" {
+		t.Fatal("unexpected proxied url", proxied)
+	}
+
+	if !p.validSignature(proxied.Query().Get("u"), proxied.Query().Get("s")) {
+		t.Fatal("expected the signature to be valid")
+	}
+
+	if p.validSignature("http://example.com/other.png", proxied.Query().Get("s")) {
+		t.Fatal("expected the signature not to be valid for another url")
+	}
+
+	if newImageProxy().validSignature(proxied.Query().Get("u"), proxied.Query().Get("s")) {
+		t.Fatal("expected the signature not to be valid with another key")
+	}
+
+	if got := p.URL("data:image/png;base64,AAAA"); got != "data:image/png;base64,AAAA" {
+		t.Fatal("expected non http urls to be left alone, got", got)
+	}
+}
+
+func TestImageProxy_RewriteHTML(t *testing.T) {
+	p := newImageProxy()
+
+	rewritten := string(p.rewriteHTML(`<p><img src="http://example.com/a.png" alt="A"/> <a href="http://example.com/">link</a></p>`))
+
+	if !strings.Contains(rewritten, `<img src="/img?s=`) || !strings.Contains(rewritten, `alt="A"`) {
+		t.Fatal("expected the image to be proxied, got", rewritten)
+	}
+
+	if !strings.Contains(rewritten, `<a href="http://example.com/">link</a>`) {
+		t.Fatal("expected links to be left alone, got", rewritten)
+	}
+}
+
+func TestImage(t *testing.T) {
+	var fetches atomic.Int32
+
+	ts := newImageTestServer(t, &fetches)
+
+	svc := NewService()
+	svc.images.Dir = t.TempDir()
+	svc.images.client = ts.Client() // the test server is on loopback
+
+	for range 2 {
+		rr := imageRequest(t, svc, svc.images.URL(ts.URL+"/image.png"))
+
+		if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/png" || !bytes.Equal(rr.Body.Bytes(), testPNG) {
+			t.Fatal("unexpected response", rr.Code, rr.Header())
+		}
+
+		if rr.Header().Get("X-Content-Type-Options") != "nosniff" {
+			t.Fatal("expected nosniff")
+		}
+	}
+
+	if fetches.Load() != 1 {
+		t.Fatal("expected the second request to be from the cache, got", fetches.Load(), "fetches")
+	}
+
+	// a fresh service (e.g. after a restart) uses the cache on disk
+	restarted := NewService()
+	restarted.images.Dir = svc.images.Dir
+	restarted.images.client = ts.Client()
+
+	if rr := imageRequest(t, restarted, restarted.images.URL(ts.URL+"/image.png")); rr.Code != http.StatusOK || fetches.Load() != 1 {
+		t.Fatal("expected the image to be served from the disk cache", rr.Code, fetches.Load())
+	}
+}
+
+func TestImage_Rejected(t *testing.T) {
+	var fetches atomic.Int32
+
+	ts := newImageTestServer(t, &fetches)
+
+	svc := NewService()
+	svc.images.client = ts.Client()
+
+	unsigned := "/img?" + url.Values{"u": {ts.URL + "/image.png"}, "s": {"forged"}}.Encode()
+	if rr := imageRequest(t, svc, unsigned); rr.Code != http.StatusForbidden {
+		t.Fatal("expected a forged signature to be forbidden, got", rr.Code)
+	}
+
+	for _, path := range []string{"/pretend.png", "/image.svg", "/huge.png", "/missing.png"} {
+		if rr := imageRequest(t, svc, svc.images.URL(ts.URL+path)); rr.Code != http.StatusBadGateway {
+			t.Fatal("expected", path, "to be refused, got", rr.Code)
+		}
+	}
+
+	if fetches.Load() != 0 {
+		t.Fatal("expected nothing to be fetched for the forged signature")
+	}
+}
+
+func TestImage_RefusesPrivateAddresses(t *testing.T) {
+	var fetches atomic.Int32
+
+	ts := newImageTestServer(t, &fetches)
+
+	svc := NewService()
+
+	if rr := imageRequest(t, svc, svc.images.URL(ts.URL+"/image.png")); rr.Code != http.StatusBadGateway {
+		t.Fatal("expected a loopback image to be refused, got", rr.Code)
+	}
+
+	// nor by redirecting to one
+	redirect := httptest.NewServer(http.RedirectHandler(ts.URL+"/image.png", http.StatusFound))
+	defer redirect.Close()
+
+	if _, _, err := svc.images.fetch(redirect.URL); !errors.Is(err, ErrImagePrivateAddress) {
+		t.Fatal("expected the private address to be refused, got", err)
+	}
+
+	if fetches.Load() != 0 {
+		t.Fatal("expected nothing to be fetched")
+	}
+}
+
+func TestPublicAddress(t *testing.T) {
+	for addr, expected := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"0.1.2.3":          false,
+		"224.0.0.1":        false,
+		"::ffff:127.0.0.1": false,
+	} {
+		if publicAddress(netip.MustParseAddr(addr)) != expected {
+			t.Error("expected", addr, "public to be", expected)
+		}
+	}
+}
+
+func TestImageProxy_LoadKey(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "image.key")
+
+	p := newImageProxy()
+	p.loadKey(filename)
+
+	// e.g. after a restart
+	restarted := newImageProxy()
+	restarted.loadKey(filename)
+
+	proxied, err := url.Parse(p.URL("http://example.com/a.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !restarted.validSignature(proxied.Query().Get("u"), proxied.Query().Get("s")) {
+		t.Fatal("expected the key to be kept")
+	}
+}
+
+func TestImageProxy_Evict(t *testing.T) {
+	p := newImageProxy()
+	p.Dir = t.TempDir()
+	p.maxBytes = int64(2 * len("image/png\n"+string(testPNG))) // room for two
+
+	for idx := range 3 {
+		imageURL := fmt.Sprintf("http://example.com/%d.png", idx)
+		p.store(imageURL, "image/png", testPNG)
+
+		used := time.Now().Add(time.Duration(idx-3) * time.Hour)
+		if err := os.Chtimes(p.cacheFile(imageURL), used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, _, found := p.cached("http://example.com/0.png"); found {
+		t.Fatal("expected the least recently used image to be evicted")
+	}
+
+	entries, err := os.ReadDir(p.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatal("expected 2 cached images, got", len(entries))
+	}
+}
+
+func TestItem_ProxiesImages(t *testing.T) {
+	fd := &feed{URL: "http://example.com/feed"}
+	fd.Init()
+
+	item := &wrappedItem{
+		Feed: fd,
+		Item: &gofeed.Item{
+			Title:       "Pictures",
+			Description: `<p>Inline <img src="http://example.com/inline.png" alt="inline"></p>`,
+			Image:       &gofeed.Image{URL: "http://example.com/meta.png"},
+		},
+	}
+
+	var out bytes.Buffer
+	if err := testService.templates["item.go.html"].Execute(&out, item); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, unexpected := range []string{`src="http://example.com/inline.png"`, `src="http://example.com/meta.png"`} {
+		if strings.Contains(out.String(), unexpected) {
+			t.Fatal("expected images to be proxied, found", unexpected, "in", out.String())
+		}
+	}
+
+	if strings.Count(out.String(), `src="/img?`) != 2 {
+		t.Fatal("expected 2 proxied images in", out.String())
+	}
+}
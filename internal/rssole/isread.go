@@ -93,6 +93,18 @@ func (u *unreadLut) MarkRead(id string) {
 	}
 }
 
+// MarkUnread forgets that an item was read.
+func (u *unreadLut) MarkUnread(id string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	delete(u.lut, id)
+
+	if u.activity != nil {
+		u.activity.UpdateLastModified()
+	}
+}
+
 // ExtendLifeIfFound extends the cache lifetime of an item if it exists.
 func (u *unreadLut) ExtendLifeIfFound(id string) {
 	if !u.IsUnread(id) {
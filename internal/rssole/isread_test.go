@@ -67,6 +67,17 @@ func TestIsUnread(t *testing.T) {
 	}
 }
 
+func TestMarkUnread(t *testing.T) {
+	readLut := unreadLut{}
+
+	readLut.MarkRead("item")
+	readLut.MarkUnread("item")
+
+	if !readLut.IsUnread("item") {
+		t.Fatal("item should be unread after MarkUnread")
+	}
+}
+
 func TestRemoveOld(t *testing.T) {
 	readLut := unreadLut{
 		lut: map[string]time.Time{
@@ -4,29 +4,60 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
+	"html/template"
 	"log/slog"
 	"net/url"
 	"strings"
 	"sync"
 
-	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
+	"github.com/JohannesKaufmann/html-to-markdown/v2/converter"
+	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/base"
+	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/commonmark"
 	"github.com/gomarkdown/markdown"
 	"github.com/gomarkdown/markdown/html"
 	"github.com/gomarkdown/markdown/parser"
 	"github.com/k3a/html2text"
 	"github.com/mmcdole/gofeed"
 	"github.com/mpvl/unique"
+	xhtml "golang.org/x/net/html"
 )
 
+// markdownConverter simplifies item content, keeping iframes (as html) so
+// the allowed embeds survive to be checked by sanitizeHTML.
+var markdownConverter = newMarkdownConverter()
+
+func newMarkdownConverter() *converter.Converter {
+	conv := converter.NewConverter(
+		converter.WithPlugins(
+			base.NewBasePlugin(),
+			commonmark.NewCommonmarkPlugin(),
+		),
+	)
+
+	conv.Register.TagType("iframe", converter.TagTypeBlock, converter.PriorityEarly)
+	conv.Register.RendererFor("iframe", converter.TagTypeBlock, renderEmbed, converter.PriorityEarly)
+
+	return conv
+}
+
+// renderEmbed keeps an iframe as html, without its fallback content.
+func renderEmbed(ctx converter.Context, w converter.Writer, node *xhtml.Node) converter.RenderStatus {
+	embed := &xhtml.Node{Type: xhtml.ElementNode, Data: node.Data, DataAtom: node.DataAtom, Attr: node.Attr}
+
+	return base.RenderAsHTML(ctx, w, embed)
+}
+
 type wrappedItem struct {
-	IsUnread bool
-	Feed     *feed
+	IsUnread      bool
+	IsHighlighted bool // by a filter rule
+	Feed          *feed
 	*gofeed.Item
 
 	summary         *string
 	description     *string
 	images          *[]string
 	onceDescription sync.Once
+	searchText      *string
 }
 
 func (w *wrappedItem) MarkReadID() string {
@@ -147,17 +178,19 @@ func (w *wrappedItem) Description() string {
 		// it to and from markdown.
 
 		// First convert rando HTML to Markdown....
-		doc, err := htmltomarkdown.ConvertString(*desc)
+		doc, err := markdownConverter.ConvertString(*desc)
 
 		switch {
 		case err != nil:
-			slog.Warn("htmltomarkdown.ConvertString failed, returning unsanitised content", "error", err)
+			slog.Warn("htmltomarkdown.ConvertString failed, returning sanitised original", "error", err)
 
-			w.description = desc
+			sanitised := sanitizeHTML(*desc)
+			w.description = &sanitised
 		case doc == "":
-			slog.Warn("htmltomarkdown.ConvertString result blank, using original.")
+			slog.Warn("htmltomarkdown.ConvertString result blank, using sanitised original.")
 
-			w.description = desc
+			sanitised := sanitizeHTML(*desc)
+			w.description = &sanitised
 		default:
 			// parse markdown
 			p := parser.NewWithExtensions(parser.CommonExtensions | parser.AutoHeadingIDs | parser.NoEmptyLineBeforeBlock)
@@ -175,7 +208,8 @@ func (w *wrappedItem) Description() string {
 				AbsolutePrefix: absRoot,
 				Flags:          html.CommonFlags | html.HrefTargetBlank,
 			})
-			mdHTML := string(markdown.Render(md, renderer))
+			// markdown can carry raw html (and javascript: links) straight through
+			mdHTML := sanitizeHTML(string(markdown.Render(md, renderer)))
 			w.description = &mdHTML
 		}
 	})
@@ -183,6 +217,12 @@ func (w *wrappedItem) Description() string {
 	return *w.description
 }
 
+// SafeDescription is the description for templates, which is already
+// sanitised so mustn't be escaped again.
+func (w *wrappedItem) SafeDescription() template.HTML {
+	return template.HTML(w.Description()) //nolint:gosec // sanitised by Description
+}
+
 const maxDescriptionLength = 200
 
 func (w *wrappedItem) Summary() string {
@@ -217,3 +257,34 @@ func (w *wrappedItem) ID() string {
 
 	return hex.EncodeToString(hash[:])
 }
+
+// SearchText returns the lowercased plain text of the title,
+// summary and description for matching against search terms.
+func (w *wrappedItem) SearchText() string {
+	if w.searchText != nil {
+		return *w.searchText
+	}
+
+	text := strings.ToLower(strings.Join([]string{
+		w.Title,
+		w.Summary(),
+		html2text.HTML2TextWithOptions(w.Description()),
+	}, "\n"))
+
+	w.searchText = &text
+
+	return *w.searchText
+}
+
+// MatchesAll returns true if every (lowercase) term is found in the item.
+func (w *wrappedItem) MatchesAll(terms []string) bool {
+	text := w.SearchText()
+
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -68,10 +68,9 @@ func TestDescription_HtmlSanitised(t *testing.T) {
 `,
 		},
 	}
-	expectedHTML := `<p><img src="http://example.com/example.gif" alt="my alt" /></p>
-`
+	expectedHTML := `<p><img src="http://example.com/example.gif" alt="my alt"/></p>`
 
-	d := w.Description()
+	d := strings.TrimSpace(w.Description()) // the removed iframe leaves a blank line
 
 	if d != expectedHTML {
 		t.Fatal("description not as expected. got:", d, "expected:", expectedHTML)
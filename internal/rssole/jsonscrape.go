@@ -0,0 +1,119 @@
+package rssole
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/tidwall/gjson"
+)
+
+// A JSON scrape makes a feed from a JSON endpoint (e.g. an API) rather than
+// a web page. Each field is a gjson path (https://github.com/tidwall/gjson),
+// the item fields relative to each item, e.g. for reddit...
+//
+//	{"items": "data.children", "title": "data.title", "link": "data.permalink"}
+
+const jsonScrapeMaxBody = 10 << 20
+
+var ErrJSONScrapeNotJSON = errors.New("response is not json")
+
+type jsonScrape struct {
+	Items      string `json:"items"` // the array of items, empty if it's the whole document
+	Title      string `json:"title"`
+	Link       string `json:"link,omitempty"`
+	Date       string `json:"date,omitempty"` // a date string, or unix time in seconds or milliseconds
+	DateLayout string `json:"date_layout,omitempty"`
+	Content    string `json:"content,omitempty"`
+}
+
+func fetchJSON(url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create new request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "rssole/"+Version)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get %s %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, gofeed.HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, jsonScrapeMaxBody))
+	if err != nil {
+		return nil, fmt.Errorf("read %s %w", url, err)
+	}
+
+	if !gjson.ValidBytes(body) {
+		return nil, fmt.Errorf("%s %w", url, ErrJSONScrapeNotJSON)
+	}
+
+	return body, nil
+}
+
+// extract picks the items out of a JSON document fetched from pageURL.
+func (conf *jsonScrape) extract(body []byte, pageURL string) []*scrapedItem {
+	items := []*scrapedItem{}
+
+	list := gjson.ParseBytes(body)
+	if conf.Items != "" {
+		list = list.Get(conf.Items)
+	}
+
+	list.ForEach(func(_, value gjson.Result) bool {
+		item := &scrapedItem{
+			Title: strings.TrimSpace(value.Get(conf.Title).String()),
+			raw:   value.Raw,
+		}
+
+		if conf.Link != "" {
+			item.Link = resolveURL(pageURL, value.Get(conf.Link).String())
+		}
+
+		if conf.Content != "" {
+			item.Description = value.Get(conf.Content).String()
+		}
+
+		if conf.Date != "" {
+			item.Published = jsonDate(value.Get(conf.Date), conf.DateLayout)
+		}
+
+		items = append(items, item)
+
+		return true
+	})
+
+	return items
+}
+
+// jsonDate understands unix times as well as the scraped date layouts.
+func jsonDate(value gjson.Result, layout string) *time.Time {
+	if value.Type != gjson.Number {
+		return parseScrapedDate(strings.TrimSpace(value.String()), layout)
+	}
+
+	ts := value.Int()
+	if ts > 1e11 { // too far in the future to be seconds
+		t := time.UnixMilli(ts).UTC()
+
+		return &t
+	}
+
+	t := time.Unix(ts, 0).UTC()
+
+	return &t
+}
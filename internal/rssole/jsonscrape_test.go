@@ -0,0 +1,160 @@
+package rssole
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func newJSONScrapeTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /reddit.json", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"data":{"children":[
+			{"data":{"title":"Post 1","permalink":"/r/golang/comments/1/","created_utc":1709287200.0,"selftext_html":"&lt;p&gt;Hello&lt;/p&gt;"}},
+			{"data":{"title":"","permalink":"/r/golang/comments/2/"}},
+			{"data":{"title":"Post 3","permalink":"/r/golang/comments/3/","created_utc":1709200800}}
+		]}}`)
+	})
+	mux.HandleFunc("GET /steam.json", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[
+			{"title":"News","url":"https://store.example.com/news/1","date":"2024-03-01T10:00:00Z","contents":"<b>Patch</b> notes"},
+			{"title":"More","url":"https://store.example.com/news/2","date":1709287200000}
+		]`)
+	})
+	mux.HandleFunc("GET /page", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `<html><body>not json</body></html>`)
+	})
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestJSONScrape(t *testing.T) {
+	ts := newJSONScrapeTestServer(t)
+
+	conf := scrape{
+		URLs: []string{ts.URL + "/reddit.json"},
+		JSON: &jsonScrape{
+			Items:   "data.children",
+			Title:   "data.title",
+			Link:    "data.permalink",
+			Date:    "data.created_utc",
+			Content: "data.selftext_html",
+		},
+	}
+
+	feedStr, err := conf.GeneratePseudoRssFeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := gofeed.NewParser().ParseString(feedStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parsed.Items) != 2 {
+		t.Fatal("expected the item without a title to be skipped, got", len(parsed.Items))
+	}
+
+	item := parsed.Items[0]
+
+	if item.Title != "Post 1" || item.Link != ts.URL+"/r/golang/comments/1/" {
+		t.Fatal("unexpected item", item.Title, item.Link)
+	}
+
+	if item.PublishedParsed == nil || !item.PublishedParsed.Equal(time.Unix(1709287200, 0)) {
+		t.Fatal("unexpected published date", item.Published)
+	}
+
+	if item.Description != "&lt;p&gt;Hello&lt;/p&gt;" {
+		t.Fatal("unexpected description", item.Description)
+	}
+}
+
+func TestJSONScrape_WholeDocument(t *testing.T) {
+	ts := newJSONScrapeTestServer(t)
+
+	body, err := fetchJSON(ts.URL + "/steam.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := (&jsonScrape{Title: "title", Link: "url", Date: "date", Content: "contents"}).extract(body, ts.URL)
+
+	if len(items) != 2 {
+		t.Fatal("expected 2 items, got", len(items))
+	}
+
+	expected := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	for _, item := range items {
+		if item.Published == nil || !item.Published.Equal(expected) {
+			t.Fatal("unexpected date for", item.Title, item.Published)
+		}
+	}
+
+	if items[0].Description != "<b>Patch</b> notes" || items[1].Link != "https://store.example.com/news/2" {
+		t.Fatal("unexpected items", items[0], items[1])
+	}
+}
+
+func TestJSONScrape_NotJSON(t *testing.T) {
+	ts := newJSONScrapeTestServer(t)
+
+	if _, err := fetchJSON(ts.URL + "/page"); !errors.Is(err, ErrJSONScrapeNotJSON) {
+		t.Fatal("expected a not json error, got", err)
+	}
+
+	if _, err := fetchJSON(ts.URL + "/missing"); err == nil {
+		t.Fatal("expected an error for a missing url")
+	}
+}
+
+func TestJSONScrape_Validate(t *testing.T) {
+	conf := scrape{URLs: []string{"http://example.com/api"}, JSON: &jsonScrape{Items: "items"}}
+	if err := conf.Validate(); !errors.Is(err, ErrScrapeNoSelector) {
+		t.Fatal("expected a json title to be required, got", err)
+	}
+
+	conf.JSON.Title = "title"
+	if err := conf.Validate(); err != nil {
+		t.Fatal("expected no css selectors to be needed, got", err)
+	}
+}
+
+func TestJSONScrapeFromForm(t *testing.T) {
+	data := url.Values{}
+	data.Add("scrape.urls", "http://example.com/api")
+	data.Add("scrape.json.items", "data.children")
+	data.Add("scrape.json.title", "data.title")
+
+	req := httptest.NewRequest(http.MethodPost, "/crudfeed", strings.NewReader(data.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	conf := scrapeFromForm(req)
+	if conf == nil || conf.JSON == nil || conf.JSON.Items != "data.children" || conf.JSON.Title != "data.title" {
+		t.Fatal("unexpected scrape", conf)
+	}
+
+	data.Del("scrape.json.items")
+	data.Del("scrape.json.title")
+
+	req = httptest.NewRequest(http.MethodPost, "/crudfeed", strings.NewReader(data.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	if conf := scrapeFromForm(req); conf == nil || conf.JSON != nil {
+		t.Fatal("expected a css scrape", conf)
+	}
+}
@@ -0,0 +1,250 @@
+// rssole service worker - keeps the UI usable offline.
+//
+// - /libs/ (and the page itself) are cached for offline use.
+// - Recently loaded /items, /item, /feeds and /starred fragments, and /img
+//   images, are served from the cache when the network isn't available.
+// - Marking read while offline (POST /items, or opening an /item from the
+//   cache) is queued in IndexedDB and replayed when we're back online.
+
+const VERSION = "__VERSION__";
+const STATIC_CACHE = "rssole-static-" + VERSION;
+const PAGES_CACHE = "rssole-pages";
+const MAX_CACHED_PAGES = 300;
+const QUEUE_DB = "rssole-queue";
+const QUEUE_STORE = "requests";
+
+const PRECACHE = [
+  "/",
+  "/libs/htmx.min.js",
+  "/libs/bootstrap.min.css",
+  "/libs/bootstrap.bundle.min.js",
+  "/libs/bootstrap-icons.css",
+  "/libs/bootstrap-icons.woff2",
+  "/libs/favicon.svg",
+  "/manifest.webmanifest",
+];
+
+const CACHED_PAGES = ["/items", "/item", "/feeds", "/starred"];
+
+self.addEventListener("install", (event) => {
+  event.waitUntil(
+    caches.open(STATIC_CACHE)
+      .then((cache) => cache.addAll(PRECACHE))
+      .then(() => self.skipWaiting()),
+  );
+});
+
+self.addEventListener("activate", (event) => {
+  event.waitUntil(
+    caches.keys()
+      .then((keys) => Promise.all(keys
+        .filter((key) => key.startsWith("rssole-static-") && key !== STATIC_CACHE)
+        .map((key) => caches.delete(key))))
+      .then(() => self.clients.claim()),
+  );
+});
+
+// Only real content is cached, not login redirects or errors.
+function cacheable(response) {
+  return response.ok && !response.redirected && !response.headers.has("HX-Redirect");
+}
+
+async function trimCache(cache) {
+  const keys = await cache.keys();
+  for (const key of keys.slice(0, Math.max(0, keys.length - MAX_CACHED_PAGES))) {
+    await cache.delete(key);
+  }
+}
+
+async function cacheFirst(request, cacheName) {
+  const cached = await caches.match(request);
+  if (cached) {
+    return cached;
+  }
+
+  const response = await fetch(request);
+  if (cacheable(response)) {
+    const cache = await caches.open(cacheName);
+    await cache.put(request, response.clone());
+    if (cacheName === PAGES_CACHE) {
+      await trimCache(cache);
+    }
+  }
+
+  return response;
+}
+
+async function networkFirst(request, cacheName) {
+  try {
+    const response = await fetch(request);
+    if (cacheable(response)) {
+      const cache = await caches.open(cacheName);
+      // re-adding moves it to the end, so the least recently used are trimmed
+      await cache.delete(request);
+      await cache.put(request, response.clone());
+      await trimCache(cache);
+    }
+    replayQueue();
+
+    return response;
+  } catch (err) {
+    const cached = await caches.match(request);
+    if (!cached) {
+      return offlineResponse();
+    }
+
+    // opening an item marks it read on the server, so do that later
+    if (new URL(request.url).pathname === "/item") {
+      await queueRequest(request);
+    }
+
+    return cached;
+  }
+}
+
+function offlineResponse(message) {
+  return new Response(
+    '<div class="alert alert-warning m-2">' + (message || "You're offline and this hasn't been loaded before.") + "</div>",
+    { status: 200, headers: { "Content-Type": "text/html; charset=utf-8" } },
+  );
+}
+
+// The queue of requests to replay, in IndexedDB as it outlives the worker.
+
+function openQueue() {
+  return new Promise((resolve, reject) => {
+    const open = indexedDB.open(QUEUE_DB, 1);
+    open.onupgradeneeded = () => open.result.createObjectStore(QUEUE_STORE, { autoIncrement: true });
+    open.onsuccess = () => resolve(open.result);
+    open.onerror = () => reject(open.error);
+  });
+}
+
+function queueTransaction(mode, fn) {
+  return openQueue().then((db) => new Promise((resolve, reject) => {
+    const tx = db.transaction(QUEUE_STORE, mode);
+    const result = fn(tx.objectStore(QUEUE_STORE));
+    tx.oncomplete = () => resolve(result.result);
+    tx.onerror = () => reject(tx.error);
+  }));
+}
+
+async function queueRequest(request) {
+  const body = request.method === "POST" ? await request.clone().text() : null;
+
+  await queueTransaction("readwrite", (store) => store.add({
+    url: request.url,
+    method: request.method,
+    contentType: request.headers.get("Content-Type"),
+    body: body,
+  }));
+
+  if (self.registration.sync) {
+    await self.registration.sync.register("replay").catch(() => {});
+  }
+}
+
+let replaying = null;
+
+function replayQueue() {
+  if (!replaying) {
+    replaying = doReplay().finally(() => { replaying = null; });
+  }
+
+  return replaying;
+}
+
+async function doReplay() {
+  const keys = await queueTransaction("readonly", (store) => store.getAllKeys());
+
+  for (const key of keys) {
+    const queued = await queueTransaction("readonly", (store) => store.get(key));
+    if (!queued) {
+      continue;
+    }
+
+    const headers = queued.contentType ? { "Content-Type": queued.contentType } : {};
+
+    try {
+      await fetch(queued.url, { method: queued.method, headers: headers, body: queued.body, credentials: "same-origin" });
+    } catch (err) {
+      return; // still offline, try again later
+    }
+
+    await queueTransaction("readwrite", (store) => store.delete(key));
+  }
+}
+
+self.addEventListener("sync", (event) => {
+  if (event.tag === "replay") {
+    event.waitUntil(replayQueue());
+  }
+});
+
+self.addEventListener("message", (event) => {
+  if (event.data === "replay") {
+    event.waitUntil(replayQueue());
+  }
+});
+
+async function markReadOffline(request) {
+  try {
+    const response = await fetch(request.clone());
+    replayQueue();
+
+    return response;
+  } catch (err) {
+    await queueRequest(request);
+
+    // show what we had for the feed, it'll catch up after the replay
+    const message = "You're offline, these will be marked read when you're back online.";
+    const cached = await caches.match(new Request(request.url));
+    if (!cached) {
+      return offlineResponse(message);
+    }
+
+    return new Response(
+      '<div class="alert alert-info m-2">' + message + "</div>" + await cached.text(),
+      { status: 200, headers: { "Content-Type": "text/html; charset=utf-8" } },
+    );
+  }
+}
+
+async function logout(request) {
+  // the next person to log in shouldn't see this user's items
+  await caches.delete(PAGES_CACHE);
+  await caches.open(STATIC_CACHE).then((cache) => cache.delete("/"));
+
+  return fetch(request);
+}
+
+self.addEventListener("fetch", (event) => {
+  const request = event.request;
+  const url = new URL(request.url);
+
+  if (url.origin !== self.location.origin) {
+    return;
+  }
+
+  if (request.method === "POST") {
+    if (url.pathname === "/items") {
+      event.respondWith(markReadOffline(request));
+    } else if (url.pathname === "/logout") {
+      event.respondWith(logout(request));
+    }
+
+    return;
+  }
+
+  if (request.method !== "GET") {
+    return;
+  }
+
+  if (url.pathname.startsWith("/libs/") || url.pathname === "/manifest.webmanifest") {
+    event.respondWith(cacheFirst(request, STATIC_CACHE));
+  } else if (url.pathname === "/img") {
+    event.respondWith(cacheFirst(request, PAGES_CACHE));
+  } else if (url.pathname === "/" || CACHED_PAGES.includes(url.pathname)) {
+    event.respondWith(networkFirst(request, url.pathname === "/" ? STATIC_CACHE : PAGES_CACHE));
+  }
+});
@@ -0,0 +1,226 @@
+package rssole
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// numericIDRetention is how long an unseen id is remembered, long enough
+// that anything still held by a client (or in the archive) keeps its id.
+const numericIDRetention = 90 * 24 * time.Hour
+
+// numericIDSeenResolution is how stale an id's Seen can get before it's
+// refreshed, so every sync request doesn't rewrite the ids file.
+const numericIDSeenResolution = 24 * time.Hour
+
+type numericID struct {
+	ID   int64     `json:"id"`
+	Seen time.Time `json:"seen"`
+}
+
+// numericIDs maps our hex ids (feeds, items and categories) to stable,
+// ever increasing integers for APIs that need them (e.g. Fever).
+// Ids are handed out in the order things are first seen, so a newer item
+// always has a higher id than an older one.
+type numericIDs struct {
+	Filename string `json:"-"`
+
+	Last int64                 `json:"last"`
+	IDs  map[string]*numericID `json:"ids"`
+
+	dirty bool // ids have changed since the last Persist
+	mu    sync.Mutex
+}
+
+func (n *numericIDs) loadIDs() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	body, err := os.ReadFile(n.Filename)
+	if err != nil {
+		slog.Error("ReadFile failed", "filename", n.Filename, "error", err)
+	} else {
+		err = json.Unmarshal(body, n)
+		if err != nil {
+			slog.Error("error unmarshal", "filename", n.Filename, "error", err)
+		}
+	}
+}
+
+// Get returns the numeric id for the key, allocating one if needed.
+func (n *numericIDs) Get(key string) int64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.IDs == nil {
+		n.IDs = map[string]*numericID{}
+	}
+
+	nid, found := n.IDs[key]
+	if !found {
+		n.Last++
+		nid = &numericID{ID: n.Last}
+		n.IDs[key] = nid
+	}
+
+	if now := time.Now(); now.Sub(nid.Seen) > numericIDSeenResolution {
+		nid.Seen = now
+		n.dirty = true
+	}
+
+	return nid.ID
+}
+
+// removeOldEntries forgets ids not seen since before.
+// Last is kept so ids are never reused.
+func (n *numericIDs) removeOldEntries(before time.Time) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for key, nid := range n.IDs {
+		if nid.Seen.Before(before) {
+			delete(n.IDs, key)
+
+			n.dirty = true
+		}
+	}
+}
+
+// Persist saves the ids to disk, if they have changed, forgetting any that
+// have not been seen for a long time.
+func (n *numericIDs) Persist() {
+	n.removeOldEntries(time.Now().Add(-numericIDRetention))
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.dirty {
+		return
+	}
+
+	jsonString, err := json.Marshal(n)
+	if err != nil {
+		slog.Error("error marshaling numeric ids", "error", err)
+
+		return
+	}
+
+	err = os.WriteFile(n.Filename, jsonString, lutFilePerms)
+	if err != nil {
+		slog.Error("error writefile", "filename", n.Filename, "error", err)
+
+		return
+	}
+
+	n.dirty = false
+}
+
+// numericRef ties an item to its numeric id and the feed it belongs to.
+type numericRef struct {
+	ID     int64
+	FeedID int64
+	Feed   *feed
+	Item   *wrappedItem
+}
+
+// numericIndex is a snapshot of every item we know about (including starred
+// snapshots) with numeric ids, oldest id first.
+type numericIndex struct {
+	refs   []*numericRef
+	byID   map[int64]*numericRef
+	feeds  map[int64]*feed
+	groups map[int64]string
+}
+
+func itemTime(i *wrappedItem) time.Time {
+	if i.PublishedParsed != nil {
+		return *i.PublishedParsed
+	}
+
+	if i.UpdatedParsed != nil {
+		return *i.UpdatedParsed
+	}
+
+	return time.Time{}
+}
+
+func categoryKey(category string) string {
+	return "category:" + category
+}
+
+// numericIndex allocates numeric ids to anything new. New items are
+// allocated oldest first, so ids follow publish order where possible.
+func (s *Service) numericIndex() *numericIndex {
+	idx := &numericIndex{
+		byID:   map[int64]*numericRef{},
+		feeds:  map[int64]*feed{},
+		groups: map[int64]string{},
+	}
+
+	seen := map[string]bool{}
+
+	for _, f := range s.feeds.All() {
+		feedID := s.ids.Get(f.ID())
+		idx.feeds[feedID] = f
+
+		if f.Category != "" {
+			idx.groups[s.ids.Get(categoryKey(f.Category))] = f.Category
+		}
+
+		f.mu.RLock()
+
+		for _, i := range f.Items() {
+			if !seen[i.ID()] {
+				seen[i.ID()] = true
+				idx.refs = append(idx.refs, &numericRef{FeedID: feedID, Feed: f, Item: i})
+			}
+		}
+
+		f.mu.RUnlock()
+	}
+
+	for _, i := range s.stars.All(s.feeds.list) {
+		if !seen[i.ID()] {
+			seen[i.ID()] = true
+			idx.refs = append(idx.refs, &numericRef{FeedID: s.ids.Get(i.Feed.ID()), Item: i})
+		}
+	}
+
+	sort.SliceStable(idx.refs, func(a, b int) bool {
+		return itemTime(idx.refs[a].Item).Before(itemTime(idx.refs[b].Item))
+	})
+
+	for _, ref := range idx.refs {
+		ref.ID = s.ids.Get(ref.Item.ID())
+		idx.byID[ref.ID] = ref
+	}
+
+	sort.Slice(idx.refs, func(a, b int) bool {
+		return idx.refs[a].ID < idx.refs[b].ID
+	})
+
+	s.ids.Persist()
+
+	return idx
+}
+
+// setRead marks the item read or unread, in both the read cache and
+// (if it's in a live feed) the item itself.
+func (s *Service) setRead(ref *numericRef, read bool) {
+	if ref.Feed != nil {
+		ref.Feed.mu.Lock()
+		ref.Item.IsUnread = !read
+		ref.Feed.mu.Unlock()
+	}
+
+	if read {
+		s.readLut.MarkRead(ref.Item.MarkReadID())
+	} else {
+		s.readLut.MarkUnread(ref.Item.MarkReadID())
+	}
+}
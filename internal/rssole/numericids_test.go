@@ -0,0 +1,86 @@
+package rssole
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNumericIDs_GetIsStable(t *testing.T) {
+	ids := numericIDs{}
+
+	a := ids.Get("a")
+	b := ids.Get("b")
+
+	if a != 1 || b != 2 {
+		t.Fatal("expected ids to be allocated in order", a, b)
+	}
+
+	if ids.Get("a") != a {
+		t.Fatal("expected the same id for the same key")
+	}
+}
+
+func TestNumericIDs_PersistAndReload(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "ids.json")
+
+	ids1 := numericIDs{Filename: filename}
+	ids1.Get("a")
+	ids1.Get("b")
+	ids1.Persist()
+
+	ids2 := numericIDs{Filename: filename}
+	ids2.loadIDs()
+
+	if ids2.Get("b") != 2 {
+		t.Fatal("expected b to keep its id after reloading")
+	}
+
+	if ids2.Get("c") != 3 {
+		t.Fatal("expected new ids to carry on from the last one")
+	}
+}
+
+func TestNumericIDs_RemoveOldEntriesNeverReusesIDs(t *testing.T) {
+	ids := numericIDs{}
+	ids.Get("a")
+	ids.Get("b")
+
+	ids.removeOldEntries(time.Now().Add(time.Hour))
+
+	if len(ids.IDs) != 0 {
+		t.Fatal("expected all entries to be removed")
+	}
+
+	if ids.Get("c") != 3 {
+		t.Fatal("expected ids not to be reused")
+	}
+}
+
+func TestNumericIDs_PersistOnlyWhenChanged(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "ids.json")
+
+	ids := numericIDs{Filename: filename}
+	ids.Get("a")
+	ids.Persist()
+
+	if err := os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	// getting a recently seen id again isn't worth a write
+	ids.Get("a")
+	ids.Persist()
+
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatal("expected unchanged ids not to be written", err)
+	}
+
+	ids.Get("b")
+	ids.Persist()
+
+	if _, err := os.Stat(filename); err != nil {
+		t.Fatal("expected a new id to be written", err)
+	}
+}
@@ -0,0 +1,183 @@
+package rssole
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+type opmlDoc struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    opmlHead `xml:"head"`
+	Body    opmlBody `xml:"body"`
+}
+
+type opmlHead struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type opmlBody struct {
+	Outlines []*opmlOutline `xml:"outline"`
+}
+
+type opmlOutline struct {
+	Text     string         `xml:"text,attr"`
+	Title    string         `xml:"title,attr,omitempty"`
+	Type     string         `xml:"type,attr,omitempty"`
+	XMLURL   string         `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string         `xml:"htmlUrl,attr,omitempty"`
+	Outlines []*opmlOutline `xml:"outline"`
+}
+
+// writeOPML writes the feed list as an OPML 2.0 document.
+// Categories become parent outlines, uncategorised feeds sit at the top level.
+// Scraped feeds are skipped as they have no real feed URL to share.
+func (f *feeds) writeOPML(w io.Writer) error {
+	doc := opmlDoc{
+		Version: "2.0",
+		Head: opmlHead{
+			Title:       "rssole feeds",
+			DateCreated: time.Now().Format(time.RFC1123Z),
+		},
+	}
+
+	categories := map[string]*opmlOutline{}
+
+	for _, fd := range f.list.All() {
+		fd.mu.RLock()
+		scraped := fd.Scrape != nil
+		category := fd.Category
+		outline := &opmlOutline{
+			Text:    fd.Title(),
+			Title:   fd.Title(),
+			Type:    "rss",
+			XMLURL:  fd.URL,
+			HTMLURL: fd.Link(),
+		}
+		fd.mu.RUnlock()
+
+		if scraped {
+			continue
+		}
+
+		if category == "" {
+			doc.Body.Outlines = append(doc.Body.Outlines, outline)
+
+			continue
+		}
+
+		cat, found := categories[category]
+		if !found {
+			cat = &opmlOutline{Text: category, Title: category}
+			categories[category] = cat
+			doc.Body.Outlines = append(doc.Body.Outlines, cat)
+		}
+
+		cat.Outlines = append(cat.Outlines, outline)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("writeOPML header - %w", err)
+	}
+
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+
+	if err := e.Encode(doc); err != nil {
+		return fmt.Errorf("writeOPML encode - %w", err)
+	}
+
+	return nil
+}
+
+// readOPML parses an OPML document and returns the feeds within it that
+// are not already in the feed list. The returned feeds are initialised
+// but not yet added.
+func (f *feeds) readOPML(r io.Reader) ([]*feed, error) {
+	var doc opmlDoc
+
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("readOPML decode - %w", err)
+	}
+
+	seen := map[string]bool{}
+	newFeeds := []*feed{}
+
+	var walk func(outlines []*opmlOutline, category string)
+
+	walk = func(outlines []*opmlOutline, category string) {
+		for _, o := range outlines {
+			name := o.Title
+			if name == "" {
+				name = o.Text
+			}
+
+			if o.XMLURL == "" {
+				// not a feed, so treat it as a category folder
+				walk(o.Outlines, name)
+
+				continue
+			}
+
+			if seen[o.XMLURL] || f.list.FindByURL(o.XMLURL) != nil {
+				continue
+			}
+
+			seen[o.XMLURL] = true
+
+			if name == o.XMLURL {
+				name = ""
+			}
+
+			fd := &feed{
+				URL:      o.XMLURL,
+				Name:     name,
+				Category: category,
+			}
+			fd.Init()
+
+			newFeeds = append(newFeeds, fd)
+		}
+	}
+
+	walk(doc.Body.Outlines, "")
+
+	return newFeeds, nil
+}
+
+// ImportOPML adds the feeds from an OPML document to the given config file.
+// Feeds already present (by URL) are skipped. Returns the number of feeds added.
+func ImportOPML(configFilename string, r io.Reader) (int, error) {
+	f := &feeds{}
+	if err := f.readFeedsFile(configFilename); err != nil {
+		return 0, err
+	}
+
+	newFeeds, err := f.readOPML(r)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, fd := range newFeeds {
+		f.list.Add(fd)
+	}
+
+	if err := f.saveFeedsFile(); err != nil {
+		return 0, err
+	}
+
+	return len(newFeeds), nil
+}
+
+// ExportOPML writes the feeds in the given config file as an OPML document.
+func ExportOPML(configFilename string, w io.Writer) error {
+	f := &feeds{}
+	if err := f.readFeedsFile(configFilename); err != nil {
+		return err
+	}
+
+	return f.writeOPML(w)
+}
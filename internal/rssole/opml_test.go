@@ -0,0 +1,147 @@
+package rssole
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testOPML = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0">
+  <head><title>Subscriptions</title></head>
+  <body>
+    <outline text="Top Level" type="rss" xmlUrl="http://example.com/top.xml"/>
+    <outline text="Nerd" title="Nerd">
+      <outline text="HN" title="Hacker News" type="rss" xmlUrl="http://example.com/hn.xml"/>
+      <outline text="http://example.com/untitled.xml" type="rss" xmlUrl="http://example.com/untitled.xml"/>
+    </outline>
+    <outline text="Games">
+      <outline text="Already Here" type="rss" xmlUrl="http://example.com/existing.xml"/>
+    </outline>
+  </body>
+</opml>`
+
+func TestReadOPML(t *testing.T) {
+	f := &feeds{list: newFeedList()}
+	f.list.Add(&feed{URL: "http://example.com/existing.xml"})
+
+	newFeeds, err := f.readOPML(strings.NewReader(testOPML))
+	if err != nil {
+		t.Fatal("unexpected error reading OPML", err)
+	}
+
+	if len(newFeeds) != 3 {
+		t.Fatalf("expected 3 new feeds, got %d", len(newFeeds))
+	}
+
+	expected := []struct{ URL, Name, Category string }{
+		{URL: "http://example.com/top.xml", Name: "Top Level", Category: ""},
+		{URL: "http://example.com/hn.xml", Name: "Hacker News", Category: "Nerd"},
+		{URL: "http://example.com/untitled.xml", Name: "", Category: "Nerd"},
+	}
+
+	for i, e := range expected {
+		got := newFeeds[i]
+		if got.URL != e.URL || got.Name != e.Name || got.Category != e.Category {
+			t.Errorf("feed %d: expected %q/%q/%q, got %q/%q/%q",
+				i, e.URL, e.Name, e.Category, got.URL, got.Name, got.Category)
+		}
+	}
+}
+
+func TestReadOPML_Invalid(t *testing.T) {
+	f := &feeds{list: newFeedList()}
+
+	if _, err := f.readOPML(strings.NewReader("NOT_XML")); err == nil {
+		t.Fatal("expected error reading invalid OPML")
+	}
+}
+
+func TestWriteOPML_RoundTrip(t *testing.T) {
+	f := &feeds{list: newFeedList()}
+	f.list.Add(&feed{URL: "http://example.com/1.xml", Name: "One", Category: "Cat A"})
+	f.list.Add(&feed{URL: "http://example.com/2.xml", Category: "Cat A"})
+	f.list.Add(&feed{URL: "http://example.com/3.xml", Name: "Three"})
+	f.list.Add(&feed{URL: "http://example.com/scraped", Scrape: &scrape{}})
+
+	var buf bytes.Buffer
+	if err := f.writeOPML(&buf); err != nil {
+		t.Fatal("unexpected error writing OPML", err)
+	}
+
+	if strings.Contains(buf.String(), "http://example.com/scraped") {
+		t.Error("expected scraped feed to be excluded from OPML")
+	}
+
+	// The category should only appear once, as a parent outline.
+	if strings.Count(buf.String(), `text="Cat A"`) != 1 {
+		t.Error("expected a single category outline in:", buf.String())
+	}
+
+	imported := &feeds{list: newFeedList()}
+
+	newFeeds, err := imported.readOPML(&buf)
+	if err != nil {
+		t.Fatal("unexpected error reading back OPML", err)
+	}
+
+	if len(newFeeds) != 3 {
+		t.Fatalf("expected 3 feeds back, got %d", len(newFeeds))
+	}
+
+	byURL := map[string]*feed{}
+	for _, fd := range newFeeds {
+		byURL[fd.URL] = fd
+	}
+
+	if fd := byURL["http://example.com/1.xml"]; fd == nil || fd.Name != "One" || fd.Category != "Cat A" {
+		t.Error("feed 1 did not round trip", fd)
+	}
+
+	// no name override, so the title falls back to the url and is dropped on import
+	if fd := byURL["http://example.com/2.xml"]; fd == nil || fd.Name != "" || fd.Category != "Cat A" {
+		t.Error("feed 2 did not round trip", fd)
+	}
+
+	if fd := byURL["http://example.com/3.xml"]; fd == nil || fd.Name != "Three" || fd.Category != "" {
+		t.Error("feed 3 did not round trip", fd)
+	}
+}
+
+func TestImportExportOPML(t *testing.T) {
+	dir := t.TempDir()
+	configFilename := filepath.Join(dir, "rssole.json")
+
+	err := os.WriteFile(configFilename, []byte(`{"feeds":[{"url":"http://example.com/existing.xml"}]}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	added, err := ImportOPML(configFilename, strings.NewReader(testOPML))
+	if err != nil {
+		t.Fatal("unexpected error importing OPML", err)
+	}
+
+	if added != 3 {
+		t.Fatalf("expected 3 feeds added, got %d", added)
+	}
+
+	var buf bytes.Buffer
+	if err := ExportOPML(configFilename, &buf); err != nil {
+		t.Fatal("unexpected error exporting OPML", err)
+	}
+
+	for _, expectedToFind := range []string{
+		"http://example.com/existing.xml",
+		"http://example.com/top.xml",
+		"http://example.com/hn.xml",
+		`title="Hacker News"`,
+		`text="Nerd"`,
+	} {
+		if !strings.Contains(buf.String(), expectedToFind) {
+			t.Errorf("exported OPML missing '%v' in %v", expectedToFind, buf.String())
+		}
+	}
+}
@@ -0,0 +1,497 @@
+package rssole
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// Output feeds re-publish what rssole holds (e.g. everything in a category,
+// or a scraped website) for other readers to subscribe to.
+//
+//	/out/all.{xml,atom,json}
+//	/out/starred.{xml,atom,json}
+//	/out/category/{name}.{xml,atom,json}
+//	/out/feed/{feed id}.{xml,atom,json}
+//
+// .xml is RSS 2.0, .atom is Atom 1.0 and .json is JSON Feed 1.1.
+
+const outMaxItems = 100
+
+// Another reader polling an output feed counts as client activity, so what
+// it's re-publishing stays up to date.
+func (s *Service) registerOutRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /out/{file}", s.active(s.outFeed))
+	mux.HandleFunc("GET /out/category/{file}", s.active(s.outCategoryFeed))
+	mux.HandleFunc("GET /out/feed/{file}", s.active(s.outSingleFeed))
+}
+
+// outFeed is the format independent form of an output feed.
+type outFeed struct {
+	Title   string
+	Link    string // rssole itself
+	FeedURL string // where this feed is served from
+	Items   []*outItem
+}
+
+type outItem struct {
+	ID          string
+	Title       string
+	Link        string
+	Description string
+	Summary     string
+	Author      string
+	Image       string
+	Categories  []string
+	Published   *time.Time
+	Updated     *time.Time
+}
+
+// newOutItem captures an item. Caller must hold i.Feed.mu.RLock.
+func newOutItem(i *wrappedItem) *outItem {
+	id := i.GUID
+	if id == "" {
+		id = i.MarkReadID()
+	}
+
+	author := ""
+	if i.Author != nil {
+		author = i.Author.Name
+	}
+
+	image := ""
+	if images := i.Images(); len(images) > 0 {
+		image = images[0]
+	}
+
+	return &outItem{
+		ID:          id,
+		Title:       i.Title,
+		Link:        i.Link,
+		Description: i.Description(),
+		Summary:     i.Summary(),
+		Author:      author,
+		Image:       image,
+		Categories:  i.Categories,
+		Published:   i.PublishedParsed,
+		Updated:     i.UpdatedParsed,
+	}
+}
+
+func (i *outItem) date() time.Time {
+	if i.Published != nil {
+		return *i.Published
+	}
+
+	if i.Updated != nil {
+		return *i.Updated
+	}
+
+	return time.Time{}
+}
+
+func formatTime(t *time.Time, layout string) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.Format(layout)
+}
+
+// RSS 2.0
+
+type rssDoc struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string     `xml:"title"`
+	Link          string     `xml:"link"`
+	Description   string     `xml:"description"`
+	LastBuildDate string     `xml:"lastBuildDate,omitempty"`
+	Items         []*rssItem `xml:"item"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length int    `xml:"length,attr"`
+}
+
+// rssItem is also used for the pseudo feeds of scraped websites.
+type rssItem struct {
+	Title       string        `xml:"title,omitempty"`
+	Link        string        `xml:"link,omitempty"`
+	Description string        `xml:"description,omitempty"`
+	Author      string        `xml:"author,omitempty"`
+	Categories  []string      `xml:"category"`
+	GUID        *rssGUID      `xml:"guid"`
+	PubDate     string        `xml:"pubDate,omitempty"`
+	Enclosure   *rssEnclosure `xml:"enclosure"`
+}
+
+func (o *outFeed) writeRSS(w io.Writer) error {
+	doc := rssDoc{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         o.Title,
+			Link:          o.Link,
+			Description:   o.Title,
+			LastBuildDate: time.Now().Format(time.RFC1123Z),
+		},
+	}
+
+	for _, i := range o.Items {
+		doc.Channel.Items = append(doc.Channel.Items, &rssItem{
+			Title:       i.Title,
+			Link:        i.Link,
+			Description: i.Description,
+			Author:      i.Author,
+			Categories:  i.Categories,
+			GUID:        &rssGUID{Value: i.ID},
+			PubDate:     formatTime(i.Published, time.RFC1123Z),
+		})
+	}
+
+	return writeXML(w, doc)
+}
+
+// Atom 1.0
+
+type atomFeed struct {
+	XMLName xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string       `xml:"title"`
+	ID      string       `xml:"id"`
+	Updated string       `xml:"updated"`
+	Links   []atomLink   `xml:"link"`
+	Entries []*atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Links      []atomLink     `xml:"link"`
+	Author     *atomPerson    `xml:"author"`
+	Summary    *atomText      `xml:"summary"`
+	Content    *atomText      `xml:"content"`
+	Categories []atomCategory `xml:"category"`
+}
+
+func (o *outFeed) writeAtom(w io.Writer) error {
+	doc := atomFeed{
+		Title:   o.Title,
+		ID:      o.FeedURL,
+		Updated: time.Now().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: o.FeedURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: o.Link, Rel: "alternate", Type: "text/html"},
+		},
+	}
+
+	for _, i := range o.Items {
+		updated := i.Updated
+		if updated == nil {
+			updated = i.Published
+		}
+
+		entry := &atomEntry{
+			Title:     i.Title,
+			ID:        i.ID,
+			Updated:   formatTime(updated, time.RFC3339),
+			Published: formatTime(i.Published, time.RFC3339),
+			Summary:   &atomText{Type: "text", Body: i.Summary},
+			Content:   &atomText{Type: "html", Body: i.Description},
+		}
+
+		// updated is required
+		if entry.Updated == "" {
+			entry.Updated = doc.Updated
+		}
+
+		if i.Link != "" {
+			entry.Links = append(entry.Links, atomLink{Href: i.Link, Rel: "alternate", Type: "text/html"})
+		}
+
+		if i.Author != "" {
+			entry.Author = &atomPerson{Name: i.Author}
+		}
+
+		for _, c := range i.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: c})
+		}
+
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("error writing xml header - %w", err)
+	}
+
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+
+	if err := e.Encode(doc); err != nil {
+		return fmt.Errorf("error encoding xml - %w", err)
+	}
+
+	return nil
+}
+
+// JSON Feed 1.1
+
+type jsonFeed struct {
+	Version     string          `json:"version"`
+	Title       string          `json:"title"`
+	HomePageURL string          `json:"home_page_url,omitempty"`
+	FeedURL     string          `json:"feed_url,omitempty"`
+	Items       []*jsonFeedItem `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url,omitempty"`
+	Title         string           `json:"title,omitempty"`
+	ContentHTML   string           `json:"content_html,omitempty"`
+	Summary       string           `json:"summary,omitempty"`
+	Image         string           `json:"image,omitempty"`
+	DatePublished string           `json:"date_published,omitempty"`
+	DateModified  string           `json:"date_modified,omitempty"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+}
+
+func (o *outFeed) writeJSONFeed(w io.Writer) error {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       o.Title,
+		HomePageURL: o.Link,
+		FeedURL:     o.FeedURL,
+		Items:       []*jsonFeedItem{},
+	}
+
+	for _, i := range o.Items {
+		item := &jsonFeedItem{
+			ID:            i.ID,
+			URL:           i.Link,
+			Title:         i.Title,
+			ContentHTML:   i.Description,
+			Summary:       i.Summary,
+			Image:         i.Image,
+			DatePublished: formatTime(i.Published, time.RFC3339),
+			DateModified:  formatTime(i.Updated, time.RFC3339),
+			Tags:          i.Categories,
+		}
+
+		if i.Author != "" {
+			item.Authors = []jsonFeedAuthor{{Name: i.Author}}
+		}
+
+		doc.Items = append(doc.Items, item)
+	}
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+
+	if err := e.Encode(doc); err != nil {
+		return fmt.Errorf("error encoding json feed - %w", err)
+	}
+
+	return nil
+}
+
+// Endpoints
+
+// splitOutName splits "name.ext" in to its parts, ext must be a known format.
+func splitOutName(file string) (string, string, bool) {
+	idx := strings.LastIndex(file, ".")
+	if idx == -1 {
+		return "", "", false
+	}
+
+	name, ext := file[:idx], file[idx+1:]
+
+	switch ext {
+	case "xml", "atom", "json":
+		return name, ext, true
+	}
+
+	return "", "", false
+}
+
+// collectOutItems captures the items of the feeds, newest first.
+func collectOutItems(feeds []*feed) []*outItem {
+	items := []*outItem{}
+
+	for _, f := range feeds {
+		f.mu.RLock()
+		for _, i := range f.Items() {
+			items = append(items, newOutItem(i))
+		}
+		f.mu.RUnlock()
+	}
+
+	return items
+}
+
+func baseURL(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + req.Host
+}
+
+func (s *Service) writeOutFeed(w http.ResponseWriter, req *http.Request, o *outFeed, ext string) {
+	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
+
+	sort.SliceStable(o.Items, func(a, b int) bool {
+		return o.Items[a].date().After(o.Items[b].date())
+	})
+
+	if len(o.Items) > outMaxItems {
+		o.Items = o.Items[:outMaxItems]
+	}
+
+	o.Link = baseURL(req) + "/"
+	o.FeedURL = baseURL(req) + req.URL.Path
+
+	var err error
+
+	switch ext {
+	case "xml":
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		err = o.writeRSS(w)
+	case "atom":
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		err = o.writeAtom(w)
+	case "json":
+		w.Header().Set("Content-Type", "application/feed+json; charset=utf-8")
+		err = o.writeJSONFeed(w)
+	}
+
+	if err != nil {
+		logger.Error("writeOutFeed", "error", err)
+	}
+}
+
+func (s *Service) outFeed(w http.ResponseWriter, req *http.Request) {
+	name, ext, ok := splitOutName(req.PathValue("file"))
+	if !ok {
+		http.NotFound(w, req)
+
+		return
+	}
+
+	switch name {
+	case "all":
+		s.writeOutFeed(w, req, &outFeed{
+			Title: "rssole - all",
+			Items: collectOutItems(s.feeds.All()),
+		}, ext)
+	case "starred":
+		items := []*outItem{}
+
+		for _, i := range s.stars.All(s.feeds.list) {
+			i.Feed.mu.RLock()
+			items = append(items, newOutItem(i))
+			i.Feed.mu.RUnlock()
+		}
+
+		s.writeOutFeed(w, req, &outFeed{
+			Title: "rssole - starred",
+			Items: items,
+		}, ext)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (s *Service) outCategoryFeed(w http.ResponseWriter, req *http.Request) {
+	name, ext, ok := splitOutName(req.PathValue("file"))
+	if !ok {
+		http.NotFound(w, req)
+
+		return
+	}
+
+	feeds, found := s.feeds.FeedTree()[name]
+	if !found {
+		http.NotFound(w, req)
+
+		return
+	}
+
+	s.writeOutFeed(w, req, &outFeed{
+		Title: "rssole - " + name,
+		Items: collectOutItems(feeds),
+	}, ext)
+}
+
+func (s *Service) outSingleFeed(w http.ResponseWriter, req *http.Request) {
+	name, ext, ok := splitOutName(req.PathValue("file"))
+	if !ok {
+		http.NotFound(w, req)
+
+		return
+	}
+
+	f := s.feeds.getFeedByID(name)
+	if f == nil {
+		http.NotFound(w, req)
+
+		return
+	}
+
+	f.mu.RLock()
+	title := f.Title()
+	f.mu.RUnlock()
+
+	s.writeOutFeed(w, req, &outFeed{
+		Title: title,
+		Items: collectOutItems([]*feed{f}),
+	}, ext)
+}
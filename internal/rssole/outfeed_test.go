@@ -0,0 +1,143 @@
+package rssole
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func outRequest(t *testing.T, path string, expectedStatus int) *httptest.ResponseRecorder {
+	t.Helper()
+
+	svc, _, _ := newAPITestService(t)
+
+	mux := http.NewServeMux()
+	svc.registerOutRoutes(mux)
+
+	req, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Host = "rssole.example.com"
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != expectedStatus {
+		t.Fatalf("%s returned wrong status code: got %v want %v (%s)", path, rr.Code, expectedStatus, rr.Body.String())
+	}
+
+	return rr
+}
+
+func TestOutFeed_RSS(t *testing.T) {
+	rr := outRequest(t, "/out/all.xml", http.StatusOK)
+
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "application/rss+xml") {
+		t.Fatal("unexpected content type", rr.Header().Get("Content-Type"))
+	}
+
+	var doc rssDoc
+	if err := xml.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Version != "2.0" || doc.Channel.Title != "rssole - all" || len(doc.Channel.Items) != 2 {
+		t.Fatal("unexpected rss", rr.Body.String())
+	}
+
+	if doc.Channel.Items[0].Description == "" && doc.Channel.Items[1].Description == "" {
+		t.Fatal("expected item descriptions", rr.Body.String())
+	}
+}
+
+func TestOutFeed_AtomCategory(t *testing.T) {
+	rr := outRequest(t, "/out/category/Cat.atom", http.StatusOK)
+
+	var doc atomFeed
+	if err := xml.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Title != "rssole - Cat" || len(doc.Entries) != 2 {
+		t.Fatal("unexpected atom", rr.Body.String())
+	}
+
+	if doc.ID != "http://rssole.example.com/out/category/Cat.atom" {
+		t.Fatal("unexpected feed id", doc.ID)
+	}
+
+	for _, entry := range doc.Entries {
+		if entry.ID == "" || entry.Updated == "" {
+			t.Fatal("entry missing required fields", entry)
+		}
+	}
+}
+
+func TestOutFeed_JSONFeed(t *testing.T) {
+	svc, f, _ := newAPITestService(t)
+
+	mux := http.NewServeMux()
+	svc.registerOutRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/out/feed/"+f.ID()+".json", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatal("unexpected status", rr.Code)
+	}
+
+	var doc jsonFeed
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Version != "https://jsonfeed.org/version/1.1" || doc.Title != "API Feed" || len(doc.Items) != 2 {
+		t.Fatal("unexpected json feed", rr.Body.String())
+	}
+
+	if doc.Items[0].ID == "" {
+		t.Fatal("items need an id", rr.Body.String())
+	}
+}
+
+func TestOutFeed_RecordsActivity(t *testing.T) {
+	svc, _, _ := newAPITestService(t)
+
+	mux := http.NewServeMux()
+	svc.registerOutRoutes(mux)
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/out/all.xml", nil))
+
+	svc.lastActivityMu.Lock()
+	defer svc.lastActivityMu.Unlock()
+
+	if svc.lastActivity.IsZero() {
+		t.Fatal("expected polling an output feed to count as client activity")
+	}
+}
+
+func TestOutFeed_Starred(t *testing.T) {
+	rr := outRequest(t, "/out/starred.json", http.StatusOK)
+
+	var doc jsonFeed
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Items) != 0 {
+		t.Fatal("expected no starred items", rr.Body.String())
+	}
+}
+
+func TestOutFeed_NotFound(t *testing.T) {
+	outRequest(t, "/out/all.html", http.StatusNotFound)
+	outRequest(t, "/out/nothing.xml", http.StatusNotFound)
+	outRequest(t, "/out/category/Missing.xml", http.StatusNotFound)
+	outRequest(t, "/out/feed/missing.json", http.StatusNotFound)
+}
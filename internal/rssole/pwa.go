@@ -0,0 +1,48 @@
+package rssole
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+// The service worker has to be served from / so its scope covers the whole
+// UI, so it (and the manifest) get their own routes rather than /libs/.
+
+func (s *Service) serviceWorker(w http.ResponseWriter, req *http.Request) {
+	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
+
+	js, err := wwwlibs.ReadFile("libs/sw.js")
+	if err != nil {
+		logger.Error("sw.js", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	// The version names the static cache, so upgrading rssole drops the
+	// old copies of /libs/.
+	js = []byte(strings.ReplaceAll(string(js), "__VERSION__", Version))
+
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	// Browsers check for a new worker on each visit, don't make them wait.
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(js)
+}
+
+func (s *Service) manifest(w http.ResponseWriter, req *http.Request) {
+	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
+
+	manifest, err := wwwlibs.ReadFile("libs/manifest.webmanifest")
+	if err != nil {
+		logger.Error("manifest.webmanifest", "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/manifest+json")
+	w.Header().Set("Cache-Control", "max-age=86400") // 24 hours
+	_, _ = w.Write(manifest)
+}
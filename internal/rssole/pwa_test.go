@@ -0,0 +1,95 @@
+package rssole
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServiceWorker(t *testing.T) {
+	rr := httptest.NewRecorder()
+	testService.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/sw.js", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatal("unexpected status", rr.Code)
+	}
+
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/javascript") {
+		t.Fatal("unexpected content type", rr.Header().Get("Content-Type"))
+	}
+
+	if rr.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatal("expected the worker not to be cached", rr.Header().Get("Cache-Control"))
+	}
+
+	body := rr.Body.String()
+
+	if strings.Contains(body, "__VERSION__") || !strings.Contains(body, `"`+Version+`"`) {
+		t.Fatal("expected the version to be filled in")
+	}
+
+	for _, expected := range []string{"/libs/htmx.min.js", `"/items"`, `"/item"`, "indexedDB"} {
+		if !strings.Contains(body, expected) {
+			t.Fatal("expected", expected, "in the worker")
+		}
+	}
+}
+
+func TestManifest(t *testing.T) {
+	rr := httptest.NewRecorder()
+	testService.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/manifest.webmanifest", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatal("unexpected status", rr.Code)
+	}
+
+	if rr.Header().Get("Content-Type") != "application/manifest+json" {
+		t.Fatal("unexpected content type", rr.Header().Get("Content-Type"))
+	}
+
+	var manifest struct {
+		Name     string `json:"name"`
+		StartURL string `json:"start_url"`
+		Display  string `json:"display"`
+		Icons    []struct {
+			Src string `json:"src"`
+		} `json:"icons"`
+	}
+
+	if err := json.Unmarshal(rr.Body.Bytes(), &manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	if manifest.Name == "" || manifest.StartURL != "/" || manifest.Display != "standalone" || len(manifest.Icons) == 0 {
+		t.Fatal("unexpected manifest", manifest)
+	}
+
+	for _, icon := range manifest.Icons {
+		if _, err := wwwlibs.Open(strings.TrimPrefix(icon.Src, "/")); err != nil {
+			t.Fatal("missing icon", icon.Src)
+		}
+	}
+}
+
+func TestIndex_RegistersServiceWorker(t *testing.T) {
+	defer setUpTearDown(t)(t)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(testService.index).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	for _, expected := range []string{`rel="manifest"`, `register("/sw.js")`} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Fatal("expected", expected, "in the page")
+		}
+	}
+}
+
+func TestPWAPathsArePublic(t *testing.T) {
+	for _, path := range []string{"/sw.js", "/manifest.webmanifest"} {
+		if !isPublicPath(path) {
+			t.Fatal("expected", path, "to be public")
+		}
+	}
+}
@@ -3,9 +3,9 @@ package rssole
 import (
 	"embed"
 	"fmt"
+	"html/template"
 	"io/fs"
 	"net/http"
-	"text/template"
 	"time"
 
 	"github.com/NYTimes/gziphandler"
@@ -41,7 +41,7 @@ func (s *Service) loadTemplates() error {
 			continue
 		}
 
-		pt, err := template.ParseFS(files, templatesDir+"/"+tmpl.Name(), templatesDir+"/components/*.go.html")
+		pt, err := template.New(tmpl.Name()).Funcs(s.templateFuncs()).ParseFS(files, templatesDir+"/"+tmpl.Name(), templatesDir+"/components/*.go.html")
 		if err != nil {
 			return fmt.Errorf("loadTemplates parsefs - %w", err)
 		}
@@ -52,7 +52,61 @@ func (s *Service) loadTemplates() error {
 	return nil
 }
 
-func Start(configFilename, configReadCacheFilename, listenAddress string, updateTime time.Duration) error {
+// templateFuncs are the functions available to all templates.
+func (s *Service) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"isStarred":   func(id string) bool { return s.stars.IsStarred(id) },
+		"proxyImage":  s.images.URL,
+		"proxyImages": s.images.rewriteHTML,
+	}
+}
+
+// Files are the locations of everything rssole persists, all must be writable.
+type Files struct {
+	Config    string
+	ReadCache string
+	Archive   string
+	Starred   string
+	IDs       string
+	Images    string // a directory, shared by all users
+	ImageKey  string // signs proxied image urls, shared by all users
+}
+
+// load reads everything the service persists.
+func (s *Service) load(files Files, updateTime time.Duration) error {
+	s.readLut.Filename = files.ReadCache
+	s.readLut.activity = s // wire up the activity tracker
+	s.readLut.loadReadLut()
+	s.readLut.startCleanupTicker()
+
+	s.stars.Filename = files.Starred
+	s.stars.loadStarred()
+
+	s.ids.Filename = files.IDs
+	s.ids.loadIDs()
+
+	s.images.Dir = files.Images
+	s.images.loadKey(files.ImageKey)
+
+	if err := s.feeds.readFeedsFile(files.Config); err != nil {
+		return err
+	}
+
+	s.archive.Filename = files.Archive
+	s.archive.setRetention(archiveRetention(s.feeds.Config.ArchiveDays))
+
+	if s.feeds.Config.ArchiveDays > 0 {
+		s.archive.loadArchive()
+		s.archive.prune(s.feeds.All()) // feeds may have been removed by hand
+	}
+
+	s.feeds.UpdateTime = updateTime
+	// Feed updates start on first client connection (see recordActivity)
+
+	return nil
+}
+
+func Start(files Files, listenAddress string, updateTime time.Duration) error {
 	slog.Info("RSSOLE", "version", Version)
 
 	svc := NewService()
@@ -62,41 +116,89 @@ func Start(configFilename, configReadCacheFilename, listenAddress string, update
 		return err
 	}
 
-	svc.readLut.Filename = configReadCacheFilename
-	svc.readLut.activity = svc // wire up the activity tracker
-	svc.readLut.loadReadLut()
-	svc.readLut.startCleanupTicker()
-
-	if err := svc.feeds.readFeedsFile(configFilename); err != nil {
+	if err := svc.load(files, updateTime); err != nil {
 		return err
 	}
 
-	svc.feeds.UpdateTime = updateTime
-	// Feed updates start on first client connection (see recordActivity)
+	handler := http.Handler(svc.routes())
 
-	http.HandleFunc("GET /{$}", svc.index)
-	http.HandleFunc("GET /feeds", svc.feedlist)
-	http.HandleFunc("GET /items", svc.items)
-	http.HandleFunc("POST /items", svc.items)
-	http.HandleFunc("GET /item", svc.item)
-	http.HandleFunc("GET /crudfeed", svc.crudfeedGet)
-	http.HandleFunc("POST /crudfeed", svc.crudfeedPost)
-	http.HandleFunc("GET /settings", svc.settingsGet)
-	http.HandleFunc("POST /settings", svc.settingsPost)
+	if svc.authEnabled() {
+		users, err := svc.loadUsers(files)
+		if err != nil {
+			return err
+		}
 
-	// As the static files won't change we force the browser to cache them.
-	httpFS := http.FileServer(http.FS(wwwlibs))
-	http.Handle("GET /libs/", forceCache(httpFS))
+		handler = svc.userRouter(users)
+		svc.runMainWebhooks()
+	}
 
 	slog.Info("Listening", "address", listenAddress)
 
-	if err := http.ListenAndServe(listenAddress, gziphandler.GzipHandler(http.DefaultServeMux)); err != nil {
+	if err := http.ListenAndServe(listenAddress, compress(svc.requireAuth(handler))); err != nil {
 		return fmt.Errorf("error during ListenAndServe - %w", err)
 	}
 
 	return nil
 }
 
+// routes returns a mux with all the endpoints for the service.
+func (s *Service) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /{$}", s.index)
+	mux.HandleFunc("GET /feeds", s.feedlist)
+	mux.HandleFunc("GET /events", s.events)
+	mux.HandleFunc("GET /items", s.items)
+	mux.HandleFunc("POST /items", s.items)
+	mux.HandleFunc("GET /item", s.item)
+	mux.HandleFunc("GET /search", s.search)
+	mux.HandleFunc("GET /starred", s.starred)
+	mux.HandleFunc("POST /star", s.star)
+	mux.HandleFunc("GET /crudfeed", s.crudfeedGet)
+	mux.HandleFunc("POST /crudfeed", s.crudfeedPost)
+	mux.HandleFunc("POST /scrapepreview", s.scrapePreview)
+	mux.HandleFunc("GET /opml", s.opmlGet)
+	mux.HandleFunc("POST /opml", s.opmlPost)
+	mux.HandleFunc("GET /settings", s.settingsGet)
+	mux.HandleFunc("POST /settings", s.settingsPost)
+	mux.HandleFunc("GET /login", s.loginGet)
+	mux.HandleFunc("POST /login", s.loginPost)
+	mux.HandleFunc("POST /logout", s.logout)
+	mux.HandleFunc("GET /img", s.image)
+	mux.HandleFunc("GET /sw.js", s.serviceWorker)
+	mux.HandleFunc("GET /manifest.webmanifest", s.manifest)
+
+	s.registerAPIRoutes(mux)
+
+	// Fever clients append their own query string (e.g. /fever/?api&items)
+	mux.HandleFunc("/fever/", s.fever)
+
+	s.registerGReaderRoutes(mux)
+	s.registerOutRoutes(mux)
+
+	// As the static files won't change we force the browser to cache them.
+	httpFS := http.FileServer(http.FS(wwwlibs))
+	mux.Handle("GET /libs/", forceCache(httpFS))
+
+	return mux
+}
+
+// compress gzips responses, other than the /events stream which gzip would
+// hold back until it had enough to compress.
+func compress(h http.Handler) http.Handler {
+	gzipped := gziphandler.GzipHandler(h)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/events" {
+			h.ServeHTTP(w, req)
+
+			return
+		}
+
+		gzipped.ServeHTTP(w, req)
+	})
+}
+
 func forceCache(h http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Cache-Control", "max-age=86400") // 24 hours
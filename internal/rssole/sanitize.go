@@ -0,0 +1,34 @@
+package rssole
+
+import (
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// Item content comes from anywhere on the internet, so everything shown
+// (or served by the api and output feeds) goes through an allowlist policy:
+// no scripts, styles, forms, event handlers or javascript: urls, and the
+// only iframes are video embeds.
+
+// embedSources are the iframes allowed in item content.
+var embedSources = regexp.MustCompile(`^https://(www\.)?(youtube\.com/embed/|youtube-nocookie\.com/embed/|player\.vimeo\.com/video/)`)
+
+var contentPolicy = newContentPolicy()
+
+func newContentPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+
+	p.AllowAttrs("src").Matching(embedSources).OnElements("iframe")
+	p.AllowAttrs("width", "height").Matching(bluemonday.Number).OnElements("iframe")
+	p.AllowAttrs("allowfullscreen").OnElements("iframe")
+	p.SkipElementsContent("iframe") // fallback text for browsers without iframes
+
+	return p
+}
+
+// sanitizeHTML makes item content safe to show.
+func sanitizeHTML(content string) string {
+	return contentPolicy.Sanitize(content)
+}
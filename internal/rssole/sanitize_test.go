@@ -0,0 +1,184 @@
+package rssole
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/net/html"
+)
+
+// maliciousPayloads are item contents a hostile (or compromised) feed might carry.
+var maliciousPayloads = []string{
+	`<script>alert(1)</script>`,
+	`<img src=x onerror=alert(1)>`,
+	`<a href="javascript:alert(1)">x</a>`,
+	`<a href="JaVaScRiPt:alert(1)">x</a>`,
+	`<a href="&#106;avascript:alert(1)">x</a>`,
+	"<a href=\"java\tscript:alert(1)\">x</a>",
+	`[x](javascript:alert(1))`,
+	`<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">x</a>`,
+	`<iframe src="https://evil.example.com/"></iframe>`,
+	`<iframe src="javascript:alert(1)"></iframe>`,
+	`<iframe srcdoc="<script>alert(1)</script>"></iframe>`,
+	`<svg onload=alert(1)>`,
+	`<math><mtext><table><mglyph><style><img src=x onerror=alert(1)>`,
+	`<div style="background:url(javascript:alert(1))">x</div>`,
+	`<object data="x.swf"></object><embed src="x.swf">`,
+	`<form action="javascript:alert(1)"><input type=submit formaction="javascript:alert(1)"></form>`,
+	`<base href="javascript:alert(1)//">`,
+	`<meta http-equiv="refresh" content="0;url=javascript:alert(1)">`,
+	`<p>ok <b onmouseover="alert(1)">bold</b></p>`,
+	`<![CDATA[<script>alert(1)</script>]]>`,
+	`<scr<script>ipt>alert(1)</script>`,
+	`<noscript><p title="</noscript><img src=x onerror=alert(1)>">`,
+	"<img src=\"x\" alt=\"`\" onerror=alert(1)>",
+	`<details open ontoggle=alert(1)>`,
+}
+
+var unsafeElements = map[string]bool{
+	"script": true, "style": true, "object": true, "embed": true, "form": true,
+	"input": true, "base": true, "meta": true, "link": true, "svg": true, "math": true,
+}
+
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "action": true, "formaction": true, "srcdoc": true, "data": true,
+}
+
+// assertSafeHTML fails if the html could run script.
+func assertSafeHTML(t *testing.T, content string) {
+	t.Helper()
+
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if unsafeElements[n.Data] {
+				t.Fatalf("unsafe <%s> in %q", n.Data, content)
+			}
+
+			for _, a := range n.Attr {
+				key := strings.ToLower(a.Key)
+				value := strings.ToLower(strings.Join(strings.Fields(a.Val), ""))
+
+				switch {
+				case strings.HasPrefix(key, "on"), key == "style":
+					t.Fatalf("unsafe attribute %s in %q", a.Key, content)
+				case n.Data == "iframe" && key == "src" && !embedSources.MatchString(a.Val):
+					t.Fatalf("unsafe iframe %s in %q", a.Val, content)
+				case urlAttributes[key] && (strings.HasPrefix(value, "javascript:") || strings.HasPrefix(value, "vbscript:") || strings.HasPrefix(value, "data:")):
+					t.Fatalf("unsafe url %s=%s in %q", a.Key, a.Val, content)
+				}
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+}
+
+func TestSanitizeHTML(t *testing.T) {
+	for _, payload := range maliciousPayloads {
+		assertSafeHTML(t, sanitizeHTML(payload))
+	}
+}
+
+func TestDescription_MaliciousPayloads(t *testing.T) {
+	for _, payload := range maliciousPayloads {
+		w := wrappedItem{Item: &gofeed.Item{Description: payload}}
+		assertSafeHTML(t, w.Description())
+	}
+}
+
+func TestDescription_AllowedEmbed(t *testing.T) {
+	w := wrappedItem{
+		Item: &gofeed.Item{
+			Description: `<p>Watch this...</p>
+<iframe src="https://www.youtube.com/embed/abc123" width="560" height="315" allowfullscreen>Fallback</iframe>
+<iframe src="https://tracker.example.com/embed/abc123"></iframe>`,
+		},
+	}
+
+	d := w.Description()
+
+	if !strings.Contains(d, `<iframe src="https://www.youtube.com/embed/abc123" width="560" height="315" allowfullscreen="">`) {
+		t.Fatal("expected the youtube embed to be kept, got", d)
+	}
+
+	if strings.Contains(d, "tracker.example.com") || strings.Contains(d, "Fallback") {
+		t.Fatal("expected other iframes and fallback content to be removed, got", d)
+	}
+}
+
+func TestDescription_LinksOpenInNewTab(t *testing.T) {
+	w := wrappedItem{Item: &gofeed.Item{Description: `<p>A <a href="https://example.com/">link</a></p>`}}
+
+	if d := w.Description(); !strings.Contains(d, `target="_blank"`) || !strings.Contains(d, "noopener") {
+		t.Fatal("expected links to open safely in a new tab, got", d)
+	}
+}
+
+func FuzzDescription(f *testing.F) {
+	for _, payload := range maliciousPayloads {
+		f.Add(payload)
+	}
+
+	f.Fuzz(func(t *testing.T, content string) {
+		w := wrappedItem{Item: &gofeed.Item{Description: content}}
+		assertSafeHTML(t, w.Description())
+	})
+}
+
+func TestTemplates_Escape(t *testing.T) {
+	for name, tmpl := range testService.templates {
+		// any data will do, the escaping is worked out before executing
+		err := tmpl.Execute(io.Discard, nil)
+
+		var tmplErr *template.Error
+		if errors.As(err, &tmplErr) {
+			t.Fatal(name, "does not escape -", err)
+		}
+	}
+}
+
+func TestTemplates_EscapeFeedContent(t *testing.T) {
+	fd := &feed{URL: "http://example.com/feed", Name: `<script>alert("feed")</script>`}
+	fd.Init()
+
+	item := &wrappedItem{
+		IsUnread: true,
+		Feed:     fd,
+		Item: &gofeed.Item{
+			Title:       `<script>alert("title")</script>`,
+			Description: `<img src=x onerror=alert(1)>`,
+			Link:        `javascript:alert("link")`,
+			Image:       &gofeed.Image{URL: `javascript:alert("image")`},
+			Enclosures:  []*gofeed.Enclosure{{URL: `javascript:alert("enclosure")`, Type: "audio/mpeg"}},
+		},
+	}
+
+	var out bytes.Buffer
+	if err := testService.templates["item.go.html"].Execute(&out, item); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, unexpected := range []string{`<script>alert`, `javascript:`, `onerror`} {
+		if strings.Contains(out.String(), unexpected) {
+			t.Fatal("unexpected", unexpected, "in", out.String())
+		}
+	}
+
+	if !strings.Contains(out.String(), `&lt;script&gt;alert(&#34;title&#34;)&lt;/script&gt;`) {
+		t.Fatal("expected the title to be escaped in", out.String())
+	}
+}
@@ -0,0 +1,115 @@
+package rssole
+
+import (
+	"sort"
+	"time"
+)
+
+// How often a feed is fetched starts from its own update_seconds, or the
+// global update time. Feeds without their own setting adapt to how often
+// they actually publish (but are never fetched more often than the global
+// update time). Consecutive failures back off exponentially.
+
+const (
+	maxAdaptivePeriod   = 6 * time.Hour
+	maxBackoffPeriod    = 24 * time.Hour
+	adaptiveSampleItems = 10 // most recent items used to guess how often a feed publishes
+	adaptiveMinItems    = 3
+	adaptiveFraction    = 4 // fetch this many times per typical gap between items
+)
+
+// basePeriod is the feed's own update interval, or the global one.
+// Caller must hold f.mu.RLock.
+func (f *feed) basePeriod() time.Duration {
+	if f.UpdateSeconds > 0 {
+		return time.Duration(max(f.UpdateSeconds, MinUpdateSeconds)) * time.Second
+	}
+
+	return f.updatePeriod
+}
+
+// publishPeriod is the average gap between the feed's most recent items,
+// or zero if there aren't enough dated items to tell.
+func (f *feed) publishPeriod() time.Duration {
+	times := []time.Time{}
+
+	for _, i := range f.Items() {
+		if t := itemTime(i); !t.IsZero() {
+			times = append(times, t)
+		}
+	}
+
+	if len(times) < adaptiveMinItems {
+		return 0
+	}
+
+	sort.Slice(times, func(a, b int) bool {
+		return times[a].After(times[b])
+	})
+
+	if len(times) > adaptiveSampleItems {
+		times = times[:adaptiveSampleItems]
+	}
+
+	return times[0].Sub(times[len(times)-1]) / time.Duration(len(times)-1)
+}
+
+// backoff doubles the period for every consecutive failure, up to maxBackoffPeriod.
+// Caller must hold f.mu.RLock.
+func (f *feed) backoff(period time.Duration) time.Duration {
+	backedOff := period
+	for i := 0; i < f.failures && backedOff < maxBackoffPeriod; i++ {
+		backedOff *= 2
+	}
+
+	return max(period, min(backedOff, maxBackoffPeriod))
+}
+
+// nextPeriod is how long to wait before the next fetch, which is never
+// sooner than the publisher asked for.
+// Caller must hold f.mu.RLock.
+func (f *feed) nextPeriod() time.Duration {
+	period := f.basePeriod()
+
+	if f.UpdateSeconds == 0 {
+		adaptive := min(f.publishPeriod()/adaptiveFraction, maxAdaptivePeriod)
+		period = max(period, adaptive)
+	}
+
+	return max(f.backoff(period), time.Until(f.notBefore).Round(time.Second))
+}
+
+// tooSoon is true if a fetch now, even one asked for (e.g. a client
+// becoming active), would be sooner than allowed.
+// Caller must hold f.mu.RLock.
+func (f *feed) tooSoon() bool {
+	return time.Since(f.lastPolled) < f.backoff(f.basePeriod())-time.Second ||
+		time.Now().Before(f.notBefore)
+}
+
+// forgetURL clears what was learnt from polling the feed's old URL, so the
+// new one is fetched straight away and publisher hints only apply to the
+// URL that sent them. The old items go too, otherwise everything from the
+// new URL would look new (see notifyNewItems).
+// Caller must hold f.mu.Lock.
+func (f *feed) forgetURL() {
+	f.lastPolled = time.Time{}
+	f.notBefore = time.Time{}
+	f.failures = 0
+	f.eTag = ""
+	f.lastModified = time.Time{}
+	f.wrappedItems.Store(nil)
+}
+
+// reschedule resets the ticker if the period has changed.
+func (f *feed) reschedule(ticker *time.Ticker) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	next := f.nextPeriod()
+	if next != f.tickPeriod {
+		f.log.Info("Update ticker", "update", next, "failures", f.failures)
+		ticker.Reset(next)
+		f.tickPeriod = next
+	}
+}
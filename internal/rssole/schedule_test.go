@@ -0,0 +1,178 @@
+package rssole
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// scheduleTestFeed returns a feed with items published every gap.
+func scheduleTestFeed(numItems int, gap time.Duration) *feed {
+	f := &feed{updatePeriod: time.Hour}
+	f.Init()
+
+	now := time.Now()
+	items := []*wrappedItem{}
+
+	for i := range numItems {
+		published := now.Add(-time.Duration(i) * gap)
+		items = append(items, &wrappedItem{Feed: f, Item: &gofeed.Item{PublishedParsed: &published}})
+	}
+
+	f.wrappedItems.Store(&items)
+
+	return f
+}
+
+func TestNextPeriod_Global(t *testing.T) {
+	f := scheduleTestFeed(0, 0)
+
+	if f.nextPeriod() != time.Hour {
+		t.Fatal("expected the global update time, got", f.nextPeriod())
+	}
+}
+
+func TestNextPeriod_FeedOverride(t *testing.T) {
+	f := scheduleTestFeed(0, 0)
+	f.UpdateSeconds = 7200
+
+	if f.nextPeriod() != 2*time.Hour {
+		t.Fatal("expected the feed's own update time, got", f.nextPeriod())
+	}
+
+	f.UpdateSeconds = 1 // below the minimum
+
+	if f.nextPeriod() != MinUpdateSeconds*time.Second {
+		t.Fatal("expected the minimum update time, got", f.nextPeriod())
+	}
+}
+
+func TestNextPeriod_Adaptive(t *testing.T) {
+	// publishes twice a day, so a quarter of 12 hours
+	f := scheduleTestFeed(5, 12*time.Hour)
+
+	if f.nextPeriod() != 3*time.Hour {
+		t.Fatal("expected an adaptive period of 3h, got", f.nextPeriod())
+	}
+
+	// publishes constantly, never faster than the global update time
+	f = scheduleTestFeed(5, time.Minute)
+
+	if f.nextPeriod() != time.Hour {
+		t.Fatal("expected the global update time, got", f.nextPeriod())
+	}
+
+	// publishes rarely, capped
+	f = scheduleTestFeed(5, 30*24*time.Hour)
+
+	if f.nextPeriod() != maxAdaptivePeriod {
+		t.Fatal("expected the max adaptive period, got", f.nextPeriod())
+	}
+
+	// not enough items to tell
+	f = scheduleTestFeed(2, 12*time.Hour)
+
+	if f.nextPeriod() != time.Hour {
+		t.Fatal("expected the global update time, got", f.nextPeriod())
+	}
+}
+
+func TestNextPeriod_AdaptiveIgnoredWithOverride(t *testing.T) {
+	f := scheduleTestFeed(5, 12*time.Hour)
+	f.UpdateSeconds = 1800
+
+	if f.nextPeriod() != 30*time.Minute {
+		t.Fatal("expected the feed's own update time, got", f.nextPeriod())
+	}
+}
+
+func TestNextPeriod_Backoff(t *testing.T) {
+	f := scheduleTestFeed(0, 0)
+
+	f.recordError()
+	f.recordError()
+
+	if f.nextPeriod() != 4*time.Hour {
+		t.Fatal("expected 2 failures to quadruple the period, got", f.nextPeriod())
+	}
+
+	f.lastPolled = time.Now().Add(-3 * time.Hour)
+
+	if !f.tooSoon() {
+		t.Fatal("expected requested updates to back off too")
+	}
+
+	for range 10 {
+		f.recordError()
+	}
+
+	if f.nextPeriod() != maxBackoffPeriod {
+		t.Fatal("expected the max backoff period, got", f.nextPeriod())
+	}
+
+	f.recordSuccess()
+
+	if f.nextPeriod() != time.Hour {
+		t.Fatal("expected success to reset the backoff, got", f.nextPeriod())
+	}
+}
+
+func TestNextPeriod_PublisherDelay(t *testing.T) {
+	f := scheduleTestFeed(0, 0)
+	f.deferUntil(5 * time.Hour)
+
+	if f.nextPeriod() != 5*time.Hour {
+		t.Fatal("expected the publisher's delay, got", f.nextPeriod())
+	}
+
+	if !f.tooSoon() {
+		t.Fatal("expected requested updates to wait for the publisher too")
+	}
+
+	f.deferUntil(time.Minute) // shorter than our own period
+
+	if f.nextPeriod() != time.Hour {
+		t.Fatal("expected the global update time, got", f.nextPeriod())
+	}
+}
+
+func TestReschedule(t *testing.T) {
+	f := scheduleTestFeed(0, 0)
+	f.tickPeriod = time.Hour
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	f.recordError()
+	f.reschedule(ticker)
+
+	if f.tickPeriod != 2*time.Hour {
+		t.Fatal("expected the ticker to back off, got", f.tickPeriod)
+	}
+}
+
+func TestUpdateFeed_NewURLForgetsSchedule(t *testing.T) {
+	svc := NewService()
+
+	f := &feed{URL: "http://example.com/old", updatePeriod: time.Hour}
+	f.Init()
+	svc.feeds.list.Add(f)
+
+	f.lastPolled = time.Now()
+	f.notBefore = time.Now().Add(time.Hour)
+	f.failures = 3
+	f.eTag = "old"
+
+	svc.updateFeed(f.ID(), &feed{URL: "http://example.com/old", Name: "Renamed"})
+
+	if !f.tooSoon() || f.failures != 3 {
+		t.Fatal("expected the schedule to be kept when the url is the same")
+	}
+
+	svc.updateFeed(f.ID(), &feed{URL: "http://example.com/new"})
+
+	if f.tooSoon() || f.failures != 0 || f.eTag != "" {
+		t.Fatal("expected the new url to be fetched straight away")
+	}
+}
@@ -1,75 +1,380 @@
 package rssole
 
 import (
+	"bytes"
+	"cmp"
 	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
+	"path"
 	"strings"
+	"time"
 
 	"github.com/andybalholm/cascadia"
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/exp/slog"
 	"golang.org/x/net/html"
 )
 
+const (
+	scrapeDefaultMaxPages = 5  // when following next links without a max_pages
+	scrapeMaxPages        = 50 // per starting url, whatever max_pages says
+)
+
+// scrapeDateLayouts are tried in order when a scrape has no date layout.
+var scrapeDateLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+	"02/01/2006",
+}
+
 type scrape struct {
 	URLs  []string `json:"urls"`
 	Item  string   `json:"item"`
 	Title string   `json:"title"`
 	Link  string   `json:"link"`
+
+	// Optional selectors, each with an optional attribute to take the value
+	// from instead of the element's content.
+	Description     string `json:"description,omitempty"`
+	DescriptionAttr string `json:"description_attr,omitempty"`
+	Date            string `json:"date,omitempty"`
+	DateAttr        string `json:"date_attr,omitempty"`
+	DateLayout      string `json:"date_layout,omitempty"` // go time layout, common ones are tried if empty
+	Image           string `json:"image,omitempty"`
+	ImageAttr       string `json:"image_attr,omitempty"` // src if empty
+	Author          string `json:"author,omitempty"`
+	AuthorAttr      string `json:"author_attr,omitempty"`
+
+	// Next selects the link to the next page, which is followed until there
+	// isn't one or MaxPages (including the first) have been scraped.
+	Next     string `json:"next,omitempty"`
+	MaxPages int    `json:"max_pages,omitempty"`
+
+	// JSON, if set, reads the urls as JSON rather than html, see jsonscrape.go.
+	JSON *jsonScrape `json:"json,omitempty"`
 }
 
 func (conf *scrape) GeneratePseudoRssFeed() (string, error) {
-	var rss strings.Builder
-	rss.WriteString(`<?xml version="1.0" encoding="UTF-8" ?>
-<rss version="2.0">
-<channel>
-  <title>` + conf.URLs[0] + `</title>
-  <link>` + conf.URLs[0] + `</link>
-  <description>This RSS was scraped</description>
-`)
+	doc := rssDoc{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       conf.URLs[0],
+			Link:        conf.URLs[0],
+			Description: "This RSS was scraped",
+		},
+	}
+
+	visited := map[string]bool{}
 
 	for _, url := range conf.URLs {
 		if url == "" {
 			continue
 		}
 
-		resp, err := http.Get(url)
-		if err != nil {
-			return "", fmt.Errorf("get %s %w", url, err)
+		if conf.JSON != nil {
+			body, err := fetchJSON(url)
+			if err != nil {
+				return "", err
+			}
+
+			for _, item := range conf.JSON.extract(body, url) {
+				if item.Title != "" {
+					doc.Channel.Items = append(doc.Channel.Items, item.rssItem())
+				}
+			}
+
+			continue
+		}
+
+		for pageURL, pages := url, 0; pageURL != "" && !visited[pageURL] && pages < conf.maxPages(); pages++ {
+			visited[pageURL] = true
+
+			page, err := fetchPage(pageURL)
+			if err != nil {
+				if pages == 0 {
+					return "", err
+				}
+
+				// a broken next page shouldn't lose the items we already have
+				slog.Warn("scraping next page failed", "url", pageURL, "error", err)
+
+				break
+			}
+
+			for _, item := range conf.extract(page, pageURL) {
+				if item.titleNode == nil {
+					continue
+				}
+
+				doc.Channel.Items = append(doc.Channel.Items, item.rssItem())
+			}
+
+			pageURL = conf.nextPage(page, pageURL)
+		}
+	}
+
+	var rss strings.Builder
+	if err := writeXML(&rss, doc); err != nil {
+		return "", err
+	}
+
+	return rss.String(), nil
+}
+
+// maxPages is how many pages to scrape from each of the urls.
+func (conf *scrape) maxPages() int {
+	if conf.Next == "" {
+		return 1
+	}
+
+	if conf.MaxPages <= 0 {
+		return scrapeDefaultMaxPages
+	}
+
+	return min(conf.MaxPages, scrapeMaxPages)
+}
+
+// nextPage is the url of the page after this one, empty if there isn't one.
+func (conf *scrape) nextPage(doc *html.Node, pageURL string) string {
+	if conf.Next == "" {
+		return ""
+	}
+
+	return resolveURL(pageURL, attrOr(query(doc, conf.Next), "href", ""))
+}
+
+// scrapedItem is what the selectors found for one item. titleNode is nil
+// if the title selector didn't match (and the item is skipped).
+type scrapedItem struct {
+	Title       string
+	Link        string
+	Description string
+	Published   *time.Time
+	Image       string
+	Author      string
+
+	node            *html.Node
+	titleNode       *html.Node
+	linkNode        *html.Node
+	descriptionNode *html.Node
+	dateNode        *html.Node
+	imageNode       *html.Node
+	authorNode      *html.Node
+
+	raw string // the item's json, for json scrapes
+}
+
+func (item *scrapedItem) rssItem() *rssItem {
+	ri := &rssItem{
+		Title:       item.Title,
+		Link:        item.Link,
+		Description: item.Description,
+		Author:      item.Author,
+	}
+
+	if ri.Description == "" {
+		ri.Description = item.Title
+	}
+
+	if item.Published != nil {
+		ri.PubDate = item.Published.Format(time.RFC1123Z)
+	}
+
+	if item.Image != "" {
+		ri.Enclosure = &rssEnclosure{URL: item.Image, Type: imageType(item.Image)}
+	}
+
+	return ri
+}
+
+// imageType guesses an image's mime type from its url, as enclosures need one.
+func imageType(imageURL string) string {
+	if u, err := url.Parse(imageURL); err == nil {
+		if t := mime.TypeByExtension(path.Ext(u.Path)); strings.HasPrefix(t, "image/") {
+			return t
+		}
+	}
+
+	return "image/jpeg"
+}
+
+func fetchPage(url string) (*html.Node, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create new request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "rssole/"+Version)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get %s %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, gofeed.HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
 		}
+	}
+
+	doc, err := html.Parse(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s %w", url, err)
+	}
+
+	return doc, nil
+}
 
-		if resp.StatusCode < 200 || resp.StatusCode > 299 {
-			resp.Body.Close()
+// extract applies the selectors to a page fetched from pageURL.
+func (conf *scrape) extract(doc *html.Node, pageURL string) []*scrapedItem {
+	items := []*scrapedItem{}
 
-			return "", fmt.Errorf("get non-success %d %s %w", resp.StatusCode, url, err)
+	for _, p := range queryAll(doc, conf.Item) {
+		item := &scrapedItem{node: p}
+
+		titleNode := query(p, conf.Title)
+		if titleNode != nil && titleNode.FirstChild != nil {
+			item.titleNode = titleNode
+			item.Title = titleNode.FirstChild.Data
 		}
 
-		doc, err := html.Parse(resp.Body)
-		resp.Body.Close()
+		item.linkNode = query(p, conf.Link)
+		item.Link = cmp.Or(resolveURL(pageURL, attrOr(item.linkNode, "href", "")), "(No link available)")
 
-		if err != nil {
-			return "", fmt.Errorf("parse %s %w", url, err)
+		if conf.Description != "" {
+			item.descriptionNode = query(p, conf.Description)
+			if conf.DescriptionAttr != "" {
+				item.Description = attrOr(item.descriptionNode, conf.DescriptionAttr, "")
+			} else {
+				item.Description = strings.TrimSpace(innerHTML(item.descriptionNode))
+			}
 		}
 
-		for _, p := range queryAll(doc, conf.Item) {
-			titleNode := query(p, conf.Title)
-			if titleNode != nil && titleNode.FirstChild != nil {
-				title := titleNode.FirstChild.Data
-				// title := Query(p, f.Scrape.Title).FirstChild.Data
-				link := attrOr(query(p, conf.Link), "href", "(No link available)")
-				itemRss := `  <item>
-    <title>` + title + `</title>
-    <link>` + link + `</link>
-    <description>` + title + `</description>
-  </item>
-`
-				rss.WriteString(itemRss)
+		if conf.Date != "" {
+			item.dateNode = query(p, conf.Date)
+
+			dateAttr := conf.DateAttr
+			if dateAttr == "" && attrOr(item.dateNode, "datetime", "") != "" {
+				dateAttr = "datetime" // a <time> element's machine readable date
 			}
+
+			item.Published = parseScrapedDate(nodeValue(item.dateNode, dateAttr), conf.DateLayout)
 		}
+
+		if conf.Image != "" {
+			item.imageNode = query(p, conf.Image)
+			item.Image = resolveURL(pageURL, attrOr(item.imageNode, cmp.Or(conf.ImageAttr, "src"), ""))
+		}
+
+		if conf.Author != "" {
+			item.authorNode = query(p, conf.Author)
+			item.Author = nodeValue(item.authorNode, conf.AuthorAttr)
+		}
+
+		items = append(items, item)
 	}
 
-	rss.WriteString(`</channel>
-</rss>`)
+	return items
+}
 
-	return rss.String(), nil
+// nodeValue is the named attribute of n, or its text if attr is empty.
+func nodeValue(n *html.Node, attr string) string {
+	if attr != "" {
+		return strings.TrimSpace(attrOr(n, attr, ""))
+	}
+
+	return nodeText(n)
+}
+
+// parseScrapedDate parses a scraped date with the given layout, or the
+// common layouts if there isn't one. nil if it can't be parsed.
+func parseScrapedDate(value, layout string) *time.Time {
+	if value == "" {
+		return nil
+	}
+
+	layouts := scrapeDateLayouts
+	if layout != "" {
+		layouts = []string{layout}
+	}
+
+	for _, l := range layouts {
+		if t, err := time.Parse(l, value); err == nil {
+			return &t
+		}
+	}
+
+	return nil
+}
+
+// resolveURL makes a (possibly relative) link on a page absolute.
+func resolveURL(pageURL, link string) string {
+	if link == "" {
+		return ""
+	}
+
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return link
+	}
+
+	u, err := base.Parse(link)
+	if err != nil {
+		return link
+	}
+
+	return u.String()
+}
+
+// nodeText is the text content of n with whitespace collapsed.
+func nodeText(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+
+	var b strings.Builder
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteString(" ")
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func innerHTML(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+
+	var b bytes.Buffer
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&b, c); err != nil {
+			return ""
+		}
+	}
+
+	return b.String()
 }
 
 func query(n *html.Node, query string) *html.Node {
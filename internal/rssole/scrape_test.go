@@ -4,7 +4,12 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
 )
 
 func TestScrape(t *testing.T) {
@@ -36,28 +41,28 @@ func TestScrape(t *testing.T) {
 	}))
 	defer ts2.Close()
 
-	expectedFeedStr := `<?xml version="1.0" encoding="UTF-8" ?>
+	expectedFeedStr := `<?xml version="1.0" encoding="UTF-8"?>
 <rss version="2.0">
-<channel>
-  <title>` + ts1.URL + `</title>
-  <link>` + ts1.URL + `</link>
-  <description>This RSS was scraped</description>
-  <item>
-    <title>Title 1</title>
-    <link>http://title1.com/</link>
-    <description>Title 1</description>
-  </item>
-  <item>
-    <title>Title 2</title>
-    <link>http://title2.com/</link>
-    <description>Title 2</description>
-  </item>
-  <item>
-    <title>Title 3</title>
-    <link>http://title3.com/</link>
-    <description>Title 3</description>
-  </item>
-</channel>
+  <channel>
+    <title>` + ts1.URL + `</title>
+    <link>` + ts1.URL + `</link>
+    <description>This RSS was scraped</description>
+    <item>
+      <title>Title 1</title>
+      <link>http://title1.com/</link>
+      <description>Title 1</description>
+    </item>
+    <item>
+      <title>Title 2</title>
+      <link>http://title2.com/</link>
+      <description>Title 2</description>
+    </item>
+    <item>
+      <title>Title 3</title>
+      <link>http://title3.com/</link>
+      <description>Title 3</description>
+    </item>
+  </channel>
 </rss>`
 
 	conf := scrape{
@@ -79,3 +84,226 @@ func TestScrape(t *testing.T) {
 		t.Fatal(feedStr, "does not equal", expectedFeedStr)
 	}
 }
+
+func TestScrape_OptionalFields(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintln(w, `<html>
+<body>
+	<div class="item">
+		<p class="title">Title 1</p>
+		<a class="link" href="http://title1.com/">Title 1</a>
+		<div class="summary"><p>A <b>bold</b> summary &amp; more</p></div>
+		<time datetime="2024-03-01T10:00:00Z">1st March</time>
+		<img class="thumb" src="/images/1.png">
+		<span class="byline" data-author="Jane Doe">by Jane</span>
+	</div>
+	<div class="item">
+		<p class="title">Title 2</p>
+		<a class="link" href="http://title2.com/">Title 2</a>
+		<div class="summary"><p>Older</p></div>
+		<time>not a date</time>
+	</div>
+</body>
+</html>`)
+	}))
+	defer ts.Close()
+
+	conf := scrape{
+		URLs:        []string{ts.URL + "/news/"},
+		Item:        ".item",
+		Title:       ".title",
+		Link:        ".link",
+		Description: ".summary",
+		Date:        "time",
+		Image:       ".thumb",
+		Author:      ".byline",
+		AuthorAttr:  "data-author",
+	}
+
+	feedStr, err := conf.GeneratePseudoRssFeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := gofeed.NewParser().ParseString(feedStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parsed.Items) != 2 {
+		t.Fatal("expected 2 items, got", len(parsed.Items))
+	}
+
+	item := parsed.Items[0]
+
+	if item.Description != "<p>A <b>bold</b> summary &amp; more</p>" {
+		t.Fatal("unexpected description", item.Description)
+	}
+
+	if item.PublishedParsed == nil || !item.PublishedParsed.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Fatal("unexpected published date", item.Published)
+	}
+
+	if item.Author == nil || item.Author.Name != "Jane Doe" {
+		t.Fatal("unexpected author", item.Author)
+	}
+
+	images := (&wrappedItem{Item: item}).Images()
+	if len(images) != 1 || images[0] != ts.URL+"/images/1.png" {
+		t.Fatal("expected the relative image to be resolved, got", images)
+	}
+
+	// missing and unparseable fields are left out
+	item = parsed.Items[1]
+
+	if item.PublishedParsed != nil || item.Author != nil || len(item.Enclosures) != 0 {
+		t.Fatal("expected no date, author or image", item)
+	}
+}
+
+func TestParseScrapedDate(t *testing.T) {
+	expected := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tt := range []struct {
+		value, layout string
+		ok            bool
+	}{
+		{"2024-03-01", "", true},
+		{"March 1, 2024", "", true},
+		{"1 Mar 2024", "", true},
+		{"01.03.2024", "02.01.2006", true},
+		{"01.03.2024", "", false},
+		{"", "", false},
+	} {
+		parsed := parseScrapedDate(tt.value, tt.layout)
+		if (parsed != nil) != tt.ok || (parsed != nil && !parsed.Equal(expected)) {
+			t.Fatal(tt.value, tt.layout, "unexpected", parsed)
+		}
+	}
+}
+
+func TestImageType(t *testing.T) {
+	for imageURL, expected := range map[string]string{
+		"http://example.com/a.png?w=100": "image/png",
+		"http://example.com/a.webp":      "image/webp",
+		"http://example.com/image":       "image/jpeg",
+	} {
+		if got := imageType(imageURL); !strings.EqualFold(got, expected) {
+			t.Fatal(imageURL, "expected", expected, "got", got)
+		}
+	}
+}
+
+func TestScrape_Pagination(t *testing.T) {
+	var fetches atomic.Int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fetches.Add(1)
+
+		page, next := req.URL.Path, map[string]string{
+			"/news/1": "2",       // relative to the page
+			"/news/2": "/news/3", // relative to the site
+			"/news/3": "/news/1", // back to the start, which isn't scraped again
+		}
+
+		fmt.Fprintln(w, `<html><body>
+	<div class="item"><p class="title">Title `+page+`</p><a class="link" href="/article`+page+`">more</a></div>
+	<a class="next" href="`+next[page]+`">Next</a>
+</body></html>`)
+	}))
+	defer ts.Close()
+
+	conf := scrape{
+		URLs:  []string{ts.URL + "/news/1"},
+		Item:  ".item",
+		Title: ".title",
+		Link:  ".link",
+		Next:  "a.next",
+	}
+
+	feedStr, err := conf.GeneratePseudoRssFeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, page := range []string{"1", "2", "3"} {
+		if !strings.Contains(feedStr, "<link>"+ts.URL+"/article/news/"+page+"</link>") {
+			t.Fatal("expected an absolute link for page", page, "in", feedStr)
+		}
+	}
+
+	if fetches.Load() != 3 {
+		t.Fatal("expected 3 pages to be fetched, got", fetches.Load())
+	}
+
+	fetches.Store(0)
+
+	conf.MaxPages = 2
+	if _, err := conf.GeneratePseudoRssFeed(); err != nil {
+		t.Fatal(err)
+	}
+
+	if fetches.Load() != 2 {
+		t.Fatal("expected max_pages to stop after 2 pages, got", fetches.Load())
+	}
+}
+
+func TestScrape_PaginationError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.UserAgent(), "rssole/") {
+			t.Error("expected rssole's user agent, got", req.UserAgent())
+		}
+
+		if req.URL.Path != "/news/1" {
+			http.Error(w, "broken", http.StatusInternalServerError)
+
+			return
+		}
+
+		fmt.Fprintln(w, `<html><body>
+	<div class="item"><p class="title">Title 1</p><a class="link" href="/article/1">more</a></div>
+	<a class="next" href="/news/2">Next</a>
+</body></html>`)
+	}))
+	defer ts.Close()
+
+	conf := scrape{
+		URLs:  []string{ts.URL + "/news/1"},
+		Item:  ".item",
+		Title: ".title",
+		Link:  ".link",
+		Next:  "a.next",
+	}
+
+	feedStr, err := conf.GeneratePseudoRssFeed()
+	if err != nil {
+		t.Fatal("expected a failed next page not to fail the feed", err)
+	}
+
+	if !strings.Contains(feedStr, "<title>Title 1</title>") {
+		t.Fatal("expected the first page's items", feedStr)
+	}
+
+	// the first page failing is still an error
+	conf.URLs = []string{ts.URL + "/news/2"}
+	if _, err := conf.GeneratePseudoRssFeed(); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestScrapeMaxPages(t *testing.T) {
+	for _, tt := range []struct {
+		conf     scrape
+		expected int
+	}{
+		{scrape{}, 1},
+		{scrape{MaxPages: 10}, 1}, // nothing to follow
+		{scrape{Next: "a"}, scrapeDefaultMaxPages},
+		{scrape{Next: "a", MaxPages: 3}, 3},
+		{scrape{Next: "a", MaxPages: 1000}, scrapeMaxPages},
+	} {
+		if got := tt.conf.maxPages(); got != tt.expected {
+			t.Fatal(tt.conf, "expected", tt.expected, "got", got)
+		}
+	}
+}
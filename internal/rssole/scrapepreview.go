@@ -0,0 +1,300 @@
+package rssole
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"golang.org/x/net/html"
+)
+
+// The scrape preview shows what the selectors in the feed editor extract
+// from each page, with the parts they matched highlighted, so selectors can
+// be tried out without saving and waiting for the feed to update.
+
+const (
+	scrapePreviewMaxItems = 10
+	scrapePreviewCacheFor = time.Minute // pages are re-used while iterating on selectors
+)
+
+type cachedPage struct {
+	doc     *html.Node // or
+	body    []byte     // for json scrapes
+	fetched time.Time
+}
+
+// pageCache holds recently fetched pages, so every change to a selector
+// doesn't fetch them again.
+type pageCache struct {
+	pages map[string]*cachedPage
+	mu    sync.Mutex
+}
+
+func (c *pageCache) cached(key string) (*cachedPage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, page := range c.pages {
+		if time.Since(page.fetched) > scrapePreviewCacheFor {
+			delete(c.pages, k)
+		}
+	}
+
+	page, found := c.pages[key]
+
+	return page, found
+}
+
+func (c *pageCache) store(key string, page *cachedPage) {
+	c.mu.Lock()
+	c.pages[key] = page
+	c.mu.Unlock()
+}
+
+func (c *pageCache) get(url string) (*html.Node, error) {
+	if page, found := c.cached(url); found && page.doc != nil {
+		return page.doc, nil
+	}
+
+	doc, err := fetchPage(url)
+	if err != nil {
+		return nil, err
+	}
+
+	c.store(url, &cachedPage{doc: doc, fetched: time.Now()})
+
+	return doc, nil
+}
+
+func (c *pageCache) getJSON(url string) ([]byte, error) {
+	key := "json " + url
+
+	if page, found := c.cached(key); found {
+		return page.body, nil
+	}
+
+	body, err := fetchJSON(url)
+	if err != nil {
+		return nil, err
+	}
+
+	c.store(key, &cachedPage{body: body, fetched: time.Now()})
+
+	return body, nil
+}
+
+type scrapePreviewItem struct {
+	Title       string
+	Link        string
+	Description string
+	Published   string
+	Image       string
+	Author      string
+	NoTitle     bool
+	HTML        template.HTML // escaped, with what each selector matched highlighted
+}
+
+type scrapePreviewPage struct {
+	URL     string
+	Error   string
+	Matched int
+	Items   []*scrapePreviewItem
+	NextURL string
+}
+
+func renderNode(n *html.Node) string {
+	var b bytes.Buffer
+	if err := html.Render(&b, n); err != nil {
+		return ""
+	}
+
+	return b.String()
+}
+
+// highlightItem returns the item's html, escaped for display, with what
+// each selector matched marked.
+func highlightItem(item *scrapedItem) string {
+	escaped := html.EscapeString(renderNode(item.node))
+
+	type mark struct {
+		part  string
+		class string
+	}
+
+	marks := []mark{}
+
+	for _, m := range []struct {
+		node  *html.Node
+		class string
+	}{
+		{item.titleNode, "bg-info-subtle"},
+		{item.linkNode, "bg-success-subtle"},
+		{item.descriptionNode, "bg-secondary-subtle"},
+		{item.dateNode, "bg-warning-subtle"},
+		{item.imageNode, "bg-danger-subtle"},
+		{item.authorNode, "bg-primary-subtle"},
+	} {
+		if m.node != nil && m.node.Type != html.ErrorNode {
+			marks = append(marks, mark{html.EscapeString(renderNode(m.node)), m.class})
+		}
+	}
+
+	// mark the outer one first, so the inner one can still be found inside it
+	sort.SliceStable(marks, func(a, b int) bool {
+		return len(marks[a].part) > len(marks[b].part)
+	})
+
+	for _, m := range marks {
+		if m.part != "" {
+			escaped = strings.Replace(escaped, m.part, `<mark class="`+m.class+`">`+m.part+`</mark>`, 1)
+		}
+	}
+
+	return escaped
+}
+
+func (s *Service) previewScrapePage(conf *scrape, url string) *scrapePreviewPage {
+	page := &scrapePreviewPage{URL: url}
+
+	doc, err := s.scrapePages.get(url)
+	if err != nil {
+		page.Error = err.Error()
+
+		return page
+	}
+
+	items := conf.extract(doc, url)
+	page.Matched = len(items)
+	page.NextURL = conf.nextPage(doc, url)
+
+	for _, item := range items[:min(len(items), scrapePreviewMaxItems)] {
+		previewItem := &scrapePreviewItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Description,
+			Image:       item.Image,
+			Author:      item.Author,
+			NoTitle:     item.titleNode == nil,
+			HTML:        template.HTML(highlightItem(item)), //nolint:gosec // highlightItem escapes it
+		}
+
+		if conf.DescriptionAttr == "" && item.descriptionNode != nil {
+			previewItem.Description = nodeText(item.descriptionNode) // the text rather than the markup
+		}
+
+		if item.Published != nil {
+			previewItem.Published = item.Published.Format(time.RFC1123)
+		} else if item.dateNode != nil {
+			previewItem.Published = "(date not understood)"
+		}
+
+		page.Items = append(page.Items, previewItem)
+	}
+
+	return page
+}
+
+// previewJSONPage is previewScrapePage for json scrapes, showing each
+// item's json as there's nothing to highlight.
+func (s *Service) previewJSONPage(conf *jsonScrape, url string) *scrapePreviewPage {
+	page := &scrapePreviewPage{URL: url}
+
+	body, err := s.scrapePages.getJSON(url)
+	if err != nil {
+		page.Error = err.Error()
+
+		return page
+	}
+
+	items := conf.extract(body, url)
+	page.Matched = len(items)
+
+	for _, item := range items[:min(len(items), scrapePreviewMaxItems)] {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, []byte(item.raw), "", "  "); err != nil {
+			indented.Reset()
+			indented.WriteString(item.raw)
+		}
+
+		previewItem := &scrapePreviewItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Description,
+			NoTitle:     item.Title == "",
+			HTML:        template.HTML(html.EscapeString(indented.String())), //nolint:gosec // escaped
+		}
+
+		if item.Published != nil {
+			previewItem.Published = item.Published.Format(time.RFC1123)
+		} else if conf.Date != "" {
+			previewItem.Published = "(date not understood)"
+		}
+
+		page.Items = append(page.Items, previewItem)
+	}
+
+	return page
+}
+
+func (s *Service) scrapePreview(w http.ResponseWriter, req *http.Request) {
+	logger := slog.Default().With("endpoint", req.URL, "method", req.Method)
+
+	if err := req.ParseForm(); err != nil {
+		logger.Error("ParseForm", "error", err)
+	}
+
+	data := map[string]any{}
+
+	conf := scrapeFromForm(req)
+	if conf == nil {
+		conf = &scrape{}
+	}
+
+	data["JSON"] = conf.JSON != nil
+
+	// only the item selector (or any json path) is needed to start with
+	err := conf.Validate()
+	if err != nil && !(errors.Is(err, ErrScrapeNoSelector) && (conf.Item != "" || conf.JSON != nil)) {
+		data["Error"] = err.Error()
+	} else {
+		pages := make([]*scrapePreviewPage, 0, len(conf.URLs))
+
+		var wg sync.WaitGroup
+
+		for _, url := range conf.URLs {
+			if url == "" {
+				continue
+			}
+
+			page := &scrapePreviewPage{}
+			pages = append(pages, page)
+
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				if conf.JSON != nil {
+					*page = *s.previewJSONPage(conf.JSON, url)
+				} else {
+					*page = *s.previewScrapePage(conf, url)
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		data["Pages"] = pages
+	}
+
+	if err := s.templates["scrapepreview.go.html"].Execute(w, data); err != nil {
+		logger.Error("scrapepreview.go.html", "error", err)
+	}
+}
@@ -0,0 +1,158 @@
+package rssole
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func scrapePreviewRequest(t *testing.T, data url.Values) string {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, "/scrapepreview", strings.NewReader(data.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(testService.scrapePreview).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatal("unexpected status", rr.Code)
+	}
+
+	return rr.Body.String()
+}
+
+func TestScrapePreview(t *testing.T) {
+	var fetches atomic.Int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		fmt.Fprint(w, `<html><body>
+<div class="item"><p class="title">Title 1</p><a class="link" href="http://title1.com/">more</a><time>someday</time></div>
+<div class="item"><a class="link" href="http://title2.com/">no title here</a></div>
+<a class="next" href="/page/2">Older</a>
+</body></html>`)
+	}))
+	defer ts.Close()
+
+	data := url.Values{}
+	data.Add("scrape.urls", ts.URL+"/page\r\n")
+	data.Add("scrape.item", ".item")
+	data.Add("scrape.title", ".title")
+	data.Add("scrape.link", ".link")
+	data.Add("scrape.date", "time")
+	data.Add("scrape.next", ".next")
+
+	body := scrapePreviewRequest(t, data)
+
+	for _, expected := range []string{
+		"2 items matched",
+		"<b>Title 1</b>",
+		"http://title1.com/",
+		`<mark class="bg-info-subtle">&lt;p class=&#34;title&#34;&gt;Title 1&lt;/p&gt;</mark>`,
+		"this item will be skipped",
+		"(date not understood)",
+		`<mark class="bg-warning-subtle">&lt;time&gt;someday&lt;/time&gt;</mark>`,
+		"Next page: " + ts.URL + "/page/2",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Fatal("expected", expected, "in", body)
+		}
+	}
+
+	// trying another selector re-uses the page
+	data.Set("scrape.title", "a")
+	scrapePreviewRequest(t, data)
+
+	if fetches.Load() != 1 {
+		t.Fatal("expected the page to be fetched once, got", fetches.Load())
+	}
+}
+
+func TestScrapePreview_BadSelector(t *testing.T) {
+	data := url.Values{}
+	data.Add("scrape.urls", "http://example.com/")
+	data.Add("scrape.item", "[[")
+
+	body := scrapePreviewRequest(t, data)
+
+	if !strings.Contains(body, "invalid css selector") {
+		t.Fatal("expected a selector error in", body)
+	}
+}
+
+func TestScrapePreview_JSON(t *testing.T) {
+	var fetches atomic.Int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"posts":[{"name":"Post 1","url":"http://post1.com/","at":"someday"},{"url":"http://post2.com/"}]}`)
+	}))
+	defer ts.Close()
+
+	data := url.Values{}
+	data.Add("scrape.urls", ts.URL+"\r\n")
+	data.Add("scrape.json.items", "posts")
+	data.Add("scrape.json.title", "name")
+	data.Add("scrape.json.link", "url")
+	data.Add("scrape.json.date", "at")
+
+	body := scrapePreviewRequest(t, data)
+
+	for _, expected := range []string{
+		"2 items matched",
+		"<b>Post 1</b>",
+		"http://post1.com/",
+		"&#34;name&#34;: &#34;Post 1&#34;",
+		"this item will be skipped",
+		"(date not understood)",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Fatal("expected", expected, "in", body)
+		}
+	}
+
+	if strings.Contains(body, "bg-info-subtle") {
+		t.Fatal("expected no html legend for json in", body)
+	}
+
+	// only the items path is needed to start with
+	data.Del("scrape.json.title")
+	scrapePreviewRequest(t, data)
+
+	if fetches.Load() != 1 {
+		t.Fatal("expected the json to be fetched once, got", fetches.Load())
+	}
+}
+
+func TestHighlightItem_Nested(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `<div class="item"><a class="link" href="/1"><span class="title">Title</span></a></div>`)
+	}))
+	defer ts.Close()
+
+	doc, err := fetchPage(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := (&scrape{Item: ".item", Title: ".title", Link: ".link"}).extract(doc, ts.URL)
+	if len(items) != 1 {
+		t.Fatal("expected 1 item")
+	}
+
+	highlighted := highlightItem(items[0])
+
+	if strings.Count(highlighted, "<mark") != 2 {
+		t.Fatal("expected both the link and the title inside it to be marked", highlighted)
+	}
+}
@@ -0,0 +1,48 @@
+package rssole
+
+import "strings"
+
+type searchResult struct {
+	Feed  *feed
+	Items []*wrappedItem
+}
+
+// searchTerms splits a query into lowercase terms, all of which must match.
+func searchTerms(query string) []string {
+	return strings.Fields(strings.ToLower(query))
+}
+
+// search returns the items in every feed that match all the terms,
+// grouped by feed in feed list order. Read state is refreshed from
+// the read cache as we go.
+func (f *feeds) search(terms []string, readCache ReadCache) []searchResult {
+	results := []searchResult{}
+
+	if len(terms) == 0 {
+		return results
+	}
+
+	for _, fd := range f.list.All() {
+		fd.mu.Lock()
+
+		var matched []*wrappedItem
+
+		for _, item := range fd.Items() {
+			if item.MatchesAll(terms) {
+				item.IsUnread = readCache.IsUnread(item.MarkReadID())
+				matched = append(matched, item)
+			}
+		}
+
+		fd.mu.Unlock()
+
+		if len(matched) > 0 {
+			results = append(results, searchResult{
+				Feed:  fd,
+				Items: matched,
+			})
+		}
+	}
+
+	return results
+}
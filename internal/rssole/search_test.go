@@ -0,0 +1,90 @@
+package rssole
+
+import (
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// searchTestReadCache reports everything unread except the one given id.
+type searchTestReadCache struct {
+	mockReadCache
+	readID string
+}
+
+func (m *searchTestReadCache) IsUnread(id string) bool { return id != m.readID }
+
+func newSearchTestFeed(url string, items ...*gofeed.Item) *feed {
+	f := &feed{URL: url}
+	f.Init()
+
+	wrapped := []*wrappedItem{}
+	for _, item := range items {
+		wrapped = append(wrapped, &wrappedItem{IsUnread: true, Feed: f, Item: item})
+	}
+
+	f.wrappedItems.Store(&wrapped)
+
+	return f
+}
+
+func TestSearchTerms(t *testing.T) {
+	terms := searchTerms("  Go   GENERICS ")
+
+	if len(terms) != 2 || terms[0] != "go" || terms[1] != "generics" {
+		t.Fatal("unexpected terms", terms)
+	}
+}
+
+func TestFeedsSearch(t *testing.T) {
+	f := &feeds{list: newFeedList()}
+	f.list.Add(newSearchTestFeed("http://example.com/feed1",
+		&gofeed.Item{Title: "All about Go generics", Link: "http://example.com/1"},
+		&gofeed.Item{Title: "Nothing to see here", Link: "http://example.com/2"},
+	))
+	f.list.Add(newSearchTestFeed("http://example.com/feed2",
+		&gofeed.Item{Title: "Rust news"},
+	))
+	f.list.Add(newSearchTestFeed("http://example.com/feed3",
+		&gofeed.Item{
+			Title:       "Weekly roundup",
+			Link:        "http://example.com/3",
+			Description: "<p>This week: <b>generics</b> in Go 1.18</p>",
+		},
+	))
+
+	readCache := &searchTestReadCache{readID: "http://example.com/3"}
+
+	results := f.search(searchTerms("go generics"), readCache)
+
+	if len(results) != 2 {
+		t.Fatalf("expected results from 2 feeds, got %d", len(results))
+	}
+
+	if results[0].Feed.URL != "http://example.com/feed1" || len(results[0].Items) != 1 {
+		t.Error("expected a single match from feed1")
+	}
+
+	if results[1].Feed.URL != "http://example.com/feed3" || len(results[1].Items) != 1 {
+		t.Error("expected a single match (in the description) from feed3")
+	}
+
+	if !results[0].Items[0].IsUnread {
+		t.Error("expected feed1 match to be unread")
+	}
+
+	if results[1].Items[0].IsUnread {
+		t.Error("expected feed3 match to be read")
+	}
+}
+
+func TestFeedsSearch_NoTerms(t *testing.T) {
+	f := &feeds{list: newFeedList()}
+	f.list.Add(newSearchTestFeed("http://example.com/feed1",
+		&gofeed.Item{Title: "Anything"},
+	))
+
+	if results := f.search(searchTerms("   "), &mockReadCache{}); len(results) != 0 {
+		t.Fatal("expected no results for an empty query, got", len(results))
+	}
+}
@@ -1,10 +1,14 @@
 package rssole
 
 import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
 	"sync"
-	"text/template"
 	"time"
 
+	"github.com/mmcdole/gofeed"
 	"golang.org/x/exp/slog"
 )
 
@@ -16,6 +20,17 @@ type ReadCache interface {
 	Persist()
 }
 
+// ItemArchive keeps items after they drop out of their upstream feed.
+type ItemArchive interface {
+	Merge(feedID string, items []*gofeed.Item) []*gofeed.Item
+	Persist()
+}
+
+// Notifier is told about items that are new since a feed's last update.
+type Notifier interface {
+	NewItems(f *feed, items []*wrappedItem)
+}
+
 // ActivityTracker tracks client activity and last-modified state.
 type ActivityTracker interface {
 	IsIdle() bool
@@ -28,8 +43,24 @@ type Service struct {
 	// Core state
 	feeds     *feeds
 	readLut   *unreadLut
+	archive   *itemArchive
+	stars     *starStore
+	ids       *numericIDs
+	webhooks  *webhookNotifier
 	templates map[string]*template.Template
 
+	// Pages fetched while previewing scrape selectors
+	scrapePages *pageCache
+
+	// Proxies (and caches) item images
+	images *imageProxy
+
+	// Authentication (see requireAuth)
+	passwords      *passwordChecker
+	sessions       *sessionStore
+	authenticators []Authenticator
+	greaderTokens  *greaderTokens
+
 	// Activity tracking (for idle detection)
 	lastActivity   time.Time
 	lastActivityMu sync.Mutex
@@ -38,15 +69,38 @@ type Service struct {
 	// Last modified tracking (for HTTP caching)
 	lastmodified   time.Time
 	muLastmodified sync.Mutex
+
+	// Streams told about changes (see events)
+	broker *eventBroker
 }
 
 // NewService creates a new Service instance with initialized state.
 func NewService() *Service {
-	return &Service{
-		feeds:     &feeds{list: newFeedList()},
+	s := &Service{
+		feeds:     &feeds{list: newFeedList(), pool: newFeedPool(), articles: newArticleCache()},
 		readLut:   &unreadLut{},
+		archive:   &itemArchive{},
+		stars:     &starStore{},
+		ids:       &numericIDs{},
 		templates: nil, // loaded via loadTemplates
+
+		scrapePages: &pageCache{pages: map[string]*cachedPage{}},
+		images:      newImageProxy(),
+		broker:      newEventBroker(),
+	}
+
+	s.webhooks = &webhookNotifier{config: &s.feeds.Config}
+	s.feeds.notifier = s.webhooks
+
+	s.passwords = &passwordChecker{config: &s.feeds.Config}
+	s.sessions = &sessionStore{config: &s.feeds.Config}
+	s.authenticators = []Authenticator{
+		s.sessions,
+		&basicAuthenticator{passwords: s.passwords},
 	}
+	s.greaderTokens = &greaderTokens{}
+
+	return s
 }
 
 // Ensure Service implements ActivityTracker.
@@ -66,6 +120,8 @@ func (s *Service) UpdateLastModified() {
 	}
 
 	s.muLastmodified.Unlock()
+
+	s.broker.publish()
 }
 
 // getLastmodified returns the last modified timestamp.
@@ -80,7 +136,7 @@ func (s *Service) getLastmodified() time.Time {
 func (s *Service) recordActivity() {
 	s.startOnce.Do(func() {
 		slog.Info("First client connected, starting feed updates")
-		s.feeds.BeginFeedUpdates(s.readLut, s)
+		s.feeds.BeginFeedUpdates(s.readLut, s.archive, s)
 	})
 
 	var wasIdle bool
@@ -96,8 +152,22 @@ func (s *Service) recordActivity() {
 	}
 }
 
+// active wraps a handler for clients other than the web UI (scripts, sync
+// apps and feed readers) so their requests start and keep feeds updating.
+func (s *Service) active(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		s.recordActivity()
+		h(w, req)
+	}
+}
+
 // IsIdle returns true if no client activity has occurred recently.
+// An open /events stream is activity.
 func (s *Service) IsIdle() bool {
+	if s.broker.connected() > 0 {
+		return false
+	}
+
 	s.lastActivityMu.Lock()
 	defer s.lastActivityMu.Unlock()
 
@@ -107,3 +177,129 @@ func (s *Service) IsIdle() bool {
 
 	return time.Since(s.lastActivity) > idleTimeout
 }
+
+var (
+	ErrUpdateSecondsTooLow = fmt.Errorf("update_seconds is below %d", MinUpdateSeconds)
+	ErrArchiveDaysNegative = errors.New("archive_days is negative")
+)
+
+// checkUpdateSeconds is for a feed's own update_seconds, where 0 means
+// the global setting.
+func checkUpdateSeconds(updateSeconds int) error {
+	if updateSeconds != 0 && updateSeconds < MinUpdateSeconds {
+		return ErrUpdateSecondsTooLow
+	}
+
+	return nil
+}
+
+// The feed management below is shared by the htmx and JSON endpoints.
+
+// addFeed initialises a new feed and starts it updating.
+func (s *Service) addFeed(fd *feed) {
+	fd.Init()
+	s.feeds.addFeed(fd, s.readLut, s.archive, s)
+}
+
+// updateFeed replaces the user editable fields of an existing feed. The
+// filters are only replaced if update has some (an empty list clears them),
+// as the htmx edit form doesn't include them.
+// Returns nil if the feed was not found.
+func (s *Service) updateFeed(id string, update *feed) *feed {
+	f := s.feeds.getFeedByID(id)
+	if f == nil {
+		return nil
+	}
+
+	// a new url may mean following a different feed in the pool
+	restart := f.URL != update.URL && (f.ticker != nil || f.leader() != nil)
+	if restart {
+		f.StopTickedUpdate()
+	}
+
+	urlChanged := f.URL != update.URL
+
+	f.mu.Lock()
+	if urlChanged {
+		f.forgetURL()
+	}
+
+	f.URL = update.URL
+	f.Name = update.Name
+	f.Category = update.Category
+	f.Scrape = update.Scrape
+	f.UpdateSeconds = update.UpdateSeconds
+	f.FullContent = update.FullContent
+
+	if update.Filters != nil {
+		f.Filters = update.Filters
+	}
+	f.mu.Unlock()
+
+	if restart {
+		f.StartTickedUpdate(s.feeds.UpdateTime, s.readLut, s.archive, s)
+	} else {
+		f.ChangeTickedUpdate(s.feeds.UpdateTime) // update_seconds may have changed
+	}
+
+	if urlChanged {
+		s.pruneArchive() // the old url's items
+	}
+
+	return f
+}
+
+// deleteFeed stops and removes a feed, along with its archived items.
+func (s *Service) deleteFeed(id string) {
+	s.feeds.delFeed(id)
+	s.pruneArchive()
+}
+
+// pruneArchive drops archived items for feeds that are no longer followed.
+func (s *Service) pruneArchive() {
+	s.archive.prune(s.feeds.All())
+	s.archive.Persist()
+}
+
+// markRead marks the feed items selected by the predicate as read,
+// returning how many were marked.
+func (s *Service) markRead(f *feed, selected func(*wrappedItem) bool) int {
+	marked := 0
+
+	f.mu.Lock()
+
+	for _, i := range f.Items() {
+		if selected(i) {
+			slog.Info("marking read", "MarkReadID", i.MarkReadID())
+			i.IsUnread = false
+			s.readLut.MarkRead(i.MarkReadID())
+			marked++
+		}
+	}
+
+	f.mu.Unlock()
+
+	s.readLut.Persist()
+
+	return marked
+}
+
+// changeSettings validates and applies new settings.
+func (s *Service) changeSettings(updateSeconds, archiveDays int) error {
+	if updateSeconds < MinUpdateSeconds {
+		return ErrUpdateSecondsTooLow
+	}
+
+	if archiveDays < 0 {
+		return ErrArchiveDaysNegative
+	}
+
+	if updateSeconds != s.feeds.Config.UpdateSeconds {
+		s.feeds.ChangeTickedUpdate(time.Duration(updateSeconds) * time.Second)
+	}
+
+	s.feeds.Config.ArchiveDays = archiveDays
+	s.archive.setRetention(archiveRetention(archiveDays))
+
+	return nil
+}
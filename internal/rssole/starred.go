@@ -0,0 +1,177 @@
+package rssole
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/exp/slog"
+)
+
+// starredItem is a full snapshot of an item, so it survives
+// being dropped from its upstream feed.
+type starredItem struct {
+	FeedURL   string       `json:"feed_url"`
+	FeedTitle string       `json:"feed_title"`
+	Item      *gofeed.Item `json:"item"`
+	Starred   time.Time    `json:"starred"`
+}
+
+// starStore is a json file backed store of starred items keyed by
+// wrappedItem.ID(). Unlike the read cache entries never expire.
+type starStore struct {
+	Filename string
+
+	items map[string]*starredItem
+	mu    sync.RWMutex
+}
+
+func (s *starStore) loadStarred() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body, err := os.ReadFile(s.Filename)
+	if err != nil {
+		slog.Error("ReadFile failed", "filename", s.Filename, "error", err)
+	} else {
+		err = json.Unmarshal(body, &s.items)
+		if err != nil {
+			slog.Error("error unmarshal", "filename", s.Filename, "error", err)
+		}
+	}
+}
+
+// IsStarred returns true if the item id has been starred.
+func (s *starStore) IsStarred(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, found := s.items[id]
+
+	return found
+}
+
+// Count returns the number of starred items.
+func (s *starStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.items)
+}
+
+// Star snapshots the item.
+func (s *starStore) Star(item *wrappedItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.star(item)
+}
+
+// star is Star, caller must hold s.mu.Lock.
+func (s *starStore) star(item *wrappedItem) {
+	if s.items == nil {
+		s.items = map[string]*starredItem{}
+	}
+
+	s.items[item.ID()] = &starredItem{
+		FeedURL:   item.Feed.URL,
+		FeedTitle: item.Feed.Title(),
+		Item:      item.Item,
+		Starred:   time.Now(),
+	}
+}
+
+// Unstar forgets the item.
+func (s *starStore) Unstar(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.items, id)
+}
+
+// Toggle stars or unstars the item, returning the new state.
+func (s *starStore) Toggle(item *wrappedItem) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, starred := s.items[item.ID()]; starred {
+		delete(s.items, item.ID())
+
+		return false
+	}
+
+	s.star(item)
+
+	return true
+}
+
+// Find returns the starred snapshot with the given id as a wrappedItem,
+// or nil. The feed is the live feed if it still exists, otherwise a
+// stand-in carrying the url and title it was starred from.
+func (s *starStore) Find(id string, fl *feedList) *wrappedItem {
+	s.mu.RLock()
+	si, found := s.items[id]
+	s.mu.RUnlock()
+
+	if !found {
+		return nil
+	}
+
+	return si.wrap(fl)
+}
+
+// All returns every starred item, most recently starred first.
+func (s *starStore) All(fl *feedList) []*wrappedItem {
+	s.mu.RLock()
+
+	starred := make([]*starredItem, 0, len(s.items))
+	for _, si := range s.items {
+		starred = append(starred, si)
+	}
+
+	s.mu.RUnlock()
+
+	sort.Slice(starred, func(i, j int) bool {
+		return starred[i].Starred.After(starred[j].Starred)
+	})
+
+	items := make([]*wrappedItem, len(starred))
+	for idx, si := range starred {
+		items[idx] = si.wrap(fl)
+	}
+
+	return items
+}
+
+func (si *starredItem) wrap(fl *feedList) *wrappedItem {
+	f := fl.FindByURL(si.FeedURL)
+	if f == nil {
+		f = &feed{URL: si.FeedURL, Name: si.FeedTitle}
+	}
+
+	return &wrappedItem{
+		Feed: f,
+		Item: si.Item,
+	}
+}
+
+// Persist saves the starred items to disk.
+func (s *starStore) Persist() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jsonString, err := json.Marshal(s.items)
+	if err != nil {
+		slog.Error("error marshaling starred", "error", err)
+
+		return
+	}
+
+	err = os.WriteFile(s.Filename, jsonString, lutFilePerms)
+	if err != nil {
+		slog.Error("error writefile", "filename", s.Filename, "error", err)
+	}
+}
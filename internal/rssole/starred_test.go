@@ -0,0 +1,121 @@
+package rssole
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func TestStarStore_Toggle(t *testing.T) {
+	s := starStore{}
+
+	f := &feed{URL: "http://example.com/feed", Name: "Feed Name"}
+	item := &wrappedItem{Feed: f, Item: &gofeed.Item{Link: "http://example.com/1"}}
+
+	if s.IsStarred(item.ID()) {
+		t.Fatal("item should not start starred")
+	}
+
+	if !s.Toggle(item) || !s.IsStarred(item.ID()) {
+		t.Fatal("item should be starred after first toggle")
+	}
+
+	if s.Count() != 1 {
+		t.Fatal("expected 1 starred item, got", s.Count())
+	}
+
+	if s.Toggle(item) || s.IsStarred(item.ID()) {
+		t.Fatal("item should be unstarred after second toggle")
+	}
+
+	if s.Count() != 0 {
+		t.Fatal("expected 0 starred items, got", s.Count())
+	}
+}
+
+func TestStarStore_ToggleConcurrently(t *testing.T) {
+	s := starStore{}
+
+	f := &feed{URL: "http://example.com/feed", Name: "Feed Name"}
+	item := &wrappedItem{Feed: f, Item: &gofeed.Item{Link: "http://example.com/1"}}
+
+	var wg sync.WaitGroup
+
+	// an even number of toggles always ends up unstarred
+	for range 100 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			s.Toggle(item)
+		}()
+	}
+
+	wg.Wait()
+
+	if s.IsStarred(item.ID()) {
+		t.Fatal("expected an even number of toggles to leave the item unstarred")
+	}
+}
+
+func TestStarStore_SurvivesFeedRemoval(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "starred.json")
+
+	fl := newFeedList()
+	f := &feed{URL: "http://example.com/feed", Name: "Feed Name"}
+	fl.Add(f)
+
+	item := &wrappedItem{Feed: f, Item: &gofeed.Item{Title: "Keep Me", Link: "http://example.com/1"}}
+
+	s1 := starStore{Filename: filename}
+	s1.Star(item)
+	s1.Persist()
+
+	// reload, with the feed no longer subscribed to
+	s2 := starStore{Filename: filename}
+	s2.loadStarred()
+
+	found := s2.Find(item.ID(), newFeedList())
+	if found == nil {
+		t.Fatal("expected starred item to be found after reload")
+	}
+
+	if found.Title != "Keep Me" {
+		t.Error("expected snapshot title, got", found.Title)
+	}
+
+	if found.Feed.URL != "http://example.com/feed" || found.Feed.Title() != "Feed Name" {
+		t.Error("expected stand-in feed with original url and title, got", found.Feed.URL, found.Feed.Title())
+	}
+
+	// with the feed present the live feed is used
+	if found := s2.Find(item.ID(), fl); found == nil || found.Feed != f {
+		t.Error("expected live feed to be used when present")
+	}
+}
+
+func TestStarStore_AllMostRecentFirst(t *testing.T) {
+	s := starStore{}
+	f := &feed{URL: "http://example.com/feed"}
+
+	s.Star(&wrappedItem{Feed: f, Item: &gofeed.Item{Link: "http://example.com/1"}})
+	s.Star(&wrappedItem{Feed: f, Item: &gofeed.Item{Link: "http://example.com/2"}})
+
+	// make ordering deterministic
+	for _, si := range s.items {
+		if si.Item.Link == "http://example.com/1" {
+			si.Starred = si.Starred.Add(-1)
+		}
+	}
+
+	all := s.All(newFeedList())
+	if len(all) != 2 {
+		t.Fatal("expected 2 starred items, got", len(all))
+	}
+
+	if all[0].Link != "http://example.com/2" || all[1].Link != "http://example.com/1" {
+		t.Error("expected most recently starred first")
+	}
+}
@@ -0,0 +1,187 @@
+package rssole
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+// With auth users configured each user gets their own Service, and so their
+// own subscriptions, read cache, stars etc. These live in files named after
+// the main ones (e.g. rssole.alice.json). Feeds are only fetched once however
+// many users subscribe to them (see feedPool).
+
+var (
+	validUserName = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+
+	ErrInvalidUserName = errors.New("user names may only contain letters, numbers, '.', '_' and '-'")
+)
+
+func userFilename(filename, user string) string {
+	ext := filepath.Ext(filename)
+
+	return strings.TrimSuffix(filename, ext) + "." + user + ext
+}
+
+// forUser returns the files for the user.
+func (f Files) forUser(user string) Files {
+	return Files{
+		Config:    userFilename(f.Config, user),
+		ReadCache: userFilename(f.ReadCache, user),
+		Archive:   userFilename(f.Archive, user),
+		Starred:   userFilename(f.Starred, user),
+		IDs:       userFilename(f.IDs, user),
+		Images:    f.Images,
+		ImageKey:  f.ImageKey,
+	}
+}
+
+// loadUsers creates a Service for each user, sharing sessions and the feed
+// pool with s. New users start with a copy of the main config.
+func (s *Service) loadUsers(files Files) (map[string]*Service, error) {
+	users := map[string]*Service{}
+
+	if s.feeds.Config.Sync != nil {
+		slog.Warn("The main config's sync credentials are ignored with auth users, add them to each user's config")
+	}
+
+	for user := range s.feeds.Config.Auth.Users {
+		if !validUserName.MatchString(user) {
+			return nil, fmt.Errorf("%q - %w", user, ErrInvalidUserName)
+		}
+
+		userFiles := files.forUser(user)
+
+		if _, err := os.Stat(userFiles.Config); errors.Is(err, os.ErrNotExist) {
+			if err := s.seedUserConfig(userFiles.Config); err != nil {
+				return nil, err
+			}
+		}
+
+		us := NewService()
+		us.passwords = s.passwords
+		us.sessions = s.sessions
+		us.authenticators = s.authenticators
+		us.feeds.pool = s.feeds.pool // users share fetching feeds and full content pages
+		us.feeds.articles = s.feeds.articles
+		us.images = s.images // the templates sign with its key
+
+		// the templates need their own funcs, isStarred uses the user's stars
+		if err := us.loadTemplates(); err != nil {
+			return nil, fmt.Errorf("user %s - %w", user, err)
+		}
+
+		if err := us.load(userFiles, s.feeds.UpdateTime); err != nil {
+			return nil, fmt.Errorf("user %s - %w", user, err)
+		}
+
+		users[user] = us
+	}
+
+	return users, nil
+}
+
+// runMainWebhooks starts the main config's feeds, when it has webhooks, so
+// they're still told about new items with users. The feeds join the users'
+// in the pool, so nothing is fetched twice, and are only fetched while
+// somebody is reading.
+func (s *Service) runMainWebhooks() {
+	if len(s.feeds.Config.Webhooks) == 0 {
+		return
+	}
+
+	slog.Info("Running the main config's webhooks alongside the users' feeds")
+	s.recordActivity()
+}
+
+// seedUserConfig writes a copy of the main config for a new user,
+// without the users, sync credentials or webhooks (the main config's
+// webhooks still run, see runMainWebhooks).
+func (s *Service) seedUserConfig(filename string) error {
+	seed := &feeds{
+		Config:   s.feeds.Config,
+		list:     newFeedList(),
+		filename: filename,
+	}
+	seed.Config.Auth = nil
+	seed.Config.Sync = nil
+	seed.Config.Webhooks = nil
+
+	for _, f := range s.feeds.All() {
+		seed.list.Add(&feed{
+			URL:           f.URL,
+			Name:          f.Name,
+			Category:      f.Category,
+			Scrape:        f.Scrape,
+			Filters:       f.Filters,
+			UpdateSeconds: f.UpdateSeconds,
+			FullContent:   f.FullContent,
+		})
+	}
+
+	return seed.saveFeedsFile()
+}
+
+// syncAuthenticated returns true if the request has valid credentials for
+// one of the sync APIs.
+func (s *Service) syncAuthenticated(req *http.Request) bool {
+	switch {
+	case strings.HasPrefix(req.URL.Path, "/fever/"):
+		return s.feverAuthenticated(req)
+	case req.URL.Path == greaderPrefix+"/accounts/ClientLogin":
+		return s.greaderCredentialsValid(req.FormValue("Email"), req.FormValue("Passwd"))
+	case strings.HasPrefix(req.URL.Path, greaderPrefix+"/"):
+		return s.greaderTokenValid(req)
+	}
+
+	return false
+}
+
+// isSyncPath is true for the sync APIs (Fever and Google Reader).
+func isSyncPath(path string) bool {
+	return strings.HasPrefix(path, "/fever/") || strings.HasPrefix(path, greaderPrefix+"/")
+}
+
+// userRouter sends each request to the Service of the user making it.
+// Anything without a user (e.g. the login page) goes to s.
+func (s *Service) userRouter(users map[string]*Service) http.Handler {
+	mux := s.routes()
+
+	userMuxes := map[string]*http.ServeMux{}
+	for user, us := range users {
+		userMuxes[user] = us.routes()
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if userMux, found := userMuxes[userFromContext(req.Context())]; found {
+			userMux.ServeHTTP(w, req)
+
+			return
+		}
+
+		// the sync APIs have their own credentials, set in each user's config
+		for user, us := range users {
+			if us.syncAuthenticated(req) {
+				userMuxes[user].ServeHTTP(w, req)
+
+				return
+			}
+		}
+
+		// nobody reads the main config's feeds, so its sync credentials
+		// mustn't get in
+		if isSyncPath(req.URL.Path) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+			return
+		}
+
+		mux.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,227 @@
+package rssole
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func TestFilesForUser(t *testing.T) {
+	files := Files{
+		Config:    "rssole.json",
+		ReadCache: "dir/rssole_readcache.json",
+		Archive:   "rssole_archive",
+		Starred:   "rssole_starred.json",
+		IDs:       "rssole_ids.json",
+	}.forUser("alice")
+
+	if files.Config != "rssole.alice.json" ||
+		files.ReadCache != "dir/rssole_readcache.alice.json" ||
+		files.Archive != "rssole_archive.alice" {
+		t.Fatal("unexpected user files", files)
+	}
+}
+
+func newUsersTestService(t *testing.T, users map[string]string) (*Service, Files) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	files := Files{
+		Config:    filepath.Join(dir, "rssole.json"),
+		ReadCache: filepath.Join(dir, "readcache.json"),
+		Archive:   filepath.Join(dir, "archive.json"),
+		Starred:   filepath.Join(dir, "starred.json"),
+		IDs:       filepath.Join(dir, "ids.json"),
+	}
+
+	svc := NewService()
+	svc.feeds.filename = files.Config
+	svc.feeds.Config.UpdateSeconds = 900
+	svc.feeds.Config.Auth = &AuthConfig{Users: users}
+	svc.feeds.list.Add(&feed{URL: "http://example.com/shared", Category: "Shared"})
+
+	return svc, files
+}
+
+func TestLoadUsers(t *testing.T) {
+	svc, files := newUsersTestService(t, map[string]string{"alice": "x", "bob": "y"})
+
+	users, err := svc.loadUsers(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(users) != 2 {
+		t.Fatal("expected a service per user")
+	}
+
+	alice := users["alice"]
+
+	if _, err := os.Stat(files.forUser("alice").Config); err != nil {
+		t.Fatal("expected the user config to be seeded", err)
+	}
+
+	if len(alice.feeds.All()) != 1 || alice.feeds.All()[0].URL != "http://example.com/shared" {
+		t.Fatal("expected the user to start with the main feeds")
+	}
+
+	if alice.feeds.Config.Auth != nil {
+		t.Fatal("expected users not to be copied into user configs")
+	}
+
+	if alice.feeds.pool != svc.feeds.pool || alice.sessions != svc.sessions {
+		t.Fatal("expected the feed pool and sessions to be shared")
+	}
+
+	if alice.feeds.All()[0] == users["bob"].feeds.All()[0] {
+		t.Fatal("expected each user to have their own feeds")
+	}
+}
+
+func TestLoadUsers_OwnStars(t *testing.T) {
+	svc, files := newUsersTestService(t, map[string]string{"alice": "x"})
+
+	if err := svc.loadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := svc.loadUsers(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice := users["alice"]
+	item := &wrappedItem{
+		Feed: alice.feeds.All()[0],
+		Item: &gofeed.Item{Title: "Starred by alice", Link: "http://example.com/starred"},
+	}
+	alice.stars.Star(item)
+
+	var buf bytes.Buffer
+	if err := alice.templates["items.go.html"].ExecuteTemplate(&buf, "components/itemline", item); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "bi-star-fill") {
+		t.Fatal("expected the item to show as starred for its user", buf.String())
+	}
+
+	buf.Reset()
+	if err := svc.templates["items.go.html"].ExecuteTemplate(&buf, "components/itemline", item); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(buf.String(), "bi-star-fill") {
+		t.Fatal("expected the main service not to see the user's stars")
+	}
+}
+
+func TestLoadUsers_InvalidName(t *testing.T) {
+	svc, files := newUsersTestService(t, map[string]string{"../evil": "x"})
+
+	if _, err := svc.loadUsers(files); err == nil {
+		t.Fatal("expected an invalid user name to fail")
+	}
+}
+
+func TestUserRouter(t *testing.T) {
+	svc, files := newUsersTestService(t, map[string]string{"alice": "x", "bob": "y"})
+
+	users, err := svc.loadUsers(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, us := range users {
+		us.startOnce.Do(func() {}) // requests mustn't start fetching the test feeds
+	}
+
+	users["bob"].feeds.Config.UpdateSeconds = 1800
+	users["bob"].feeds.Config.Sync = &SyncConfig{Username: "bob", Password: "sync"}
+
+	router := svc.userRouter(users)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/settings", nil)
+	req = req.WithContext(context.WithValue(req.Context(), userContextKey{}, "bob"))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var settings apiSettings
+	if err := json.Unmarshal(rr.Body.Bytes(), &settings); err != nil {
+		t.Fatal(err)
+	}
+
+	if settings.UpdateSeconds != 1800 {
+		t.Fatal("expected bob's settings", settings)
+	}
+
+	form := url.Values{"api_key": {feverAPIKey("bob", "sync")}}
+	req = httptest.NewRequest(http.MethodGet, "/fever/?api&"+form.Encode(), nil)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var fever feverTestResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &fever); err != nil {
+		t.Fatal(err)
+	}
+
+	if fever.Auth != 1 {
+		t.Fatal("expected the sync credentials to route to bob")
+	}
+
+	// the main config's sync credentials don't reach its unread feeds
+	svc.feeds.Config.Sync = &SyncConfig{Username: "main", Password: "sync"}
+
+	form = url.Values{"api_key": {feverAPIKey("main", "sync")}}
+	req = httptest.NewRequest(http.MethodGet, "/fever/?api&"+form.Encode(), nil)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatal("expected the main config's sync credentials to be refused", rr.Code, rr.Body.String())
+	}
+}
+
+func TestRunMainWebhooks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		_, _ = w.Write([]byte(`<rss version="2.0"><channel><title>Main Feed</title></channel></rss>`))
+	}))
+	defer ts.Close()
+
+	svc := NewService()
+	svc.feeds.UpdateTime = time.Hour
+
+	f := &feed{URL: ts.URL}
+	f.Init()
+	svc.feeds.list.Add(f)
+
+	defer f.StopTickedUpdate()
+
+	svc.runMainWebhooks()
+
+	if f.ticker != nil {
+		t.Fatal("expected nothing to start without webhooks")
+	}
+
+	svc.feeds.Config.Webhooks = []*webhook{{URL: "http://example.com/hook"}}
+	svc.runMainWebhooks()
+
+	if f.ticker == nil {
+		t.Fatal("expected the main config's feeds to start for its webhooks")
+	}
+}
@@ -0,0 +1,165 @@
+package rssole
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/exp/slog"
+)
+
+// Validation is a dry run of a feed: it's fetched and parsed (or scraped)
+// without being added, so problems show up before it's saved.
+
+const (
+	previewMaxItems     = 5
+	validateConcurrency = 8
+)
+
+var (
+	ErrScrapeNoURLs     = errors.New("scrape needs at least one page url")
+	ErrScrapeNoSelector = errors.New("scrape selector is required")
+	ErrScrapeSelector   = errors.New("invalid css selector")
+	ErrScrapeNoItems    = errors.New("scrape selectors matched no items")
+	ErrFeedsNotValid    = errors.New("feeds failed validation")
+)
+
+// Validate checks the scrape has pages and its selectors parse (or, for
+// a JSON scrape, that it has a title).
+func (conf *scrape) Validate() error {
+	hasURL := false
+
+	for _, u := range conf.URLs {
+		if strings.TrimSpace(u) != "" {
+			hasURL = true
+		}
+	}
+
+	if !hasURL {
+		return ErrScrapeNoURLs
+	}
+
+	if conf.JSON != nil {
+		if conf.JSON.Title == "" {
+			return fmt.Errorf("%w - json title", ErrScrapeNoSelector)
+		}
+
+		return nil
+	}
+
+	for _, sel := range []struct {
+		name, selector string
+		required       bool
+	}{
+		{"item", conf.Item, true},
+		{"title", conf.Title, true},
+		{"link", conf.Link, false},
+		{"description", conf.Description, false},
+		{"date", conf.Date, false},
+		{"image", conf.Image, false},
+		{"author", conf.Author, false},
+		{"next", conf.Next, false},
+	} {
+		if sel.selector == "" {
+			if sel.required {
+				return fmt.Errorf("%w - %s", ErrScrapeNoSelector, sel.name)
+			}
+
+			continue
+		}
+
+		if _, err := cascadia.Parse(sel.selector); err != nil {
+			return fmt.Errorf("%w for %s %q - %w", ErrScrapeSelector, sel.name, sel.selector, err)
+		}
+	}
+
+	return nil
+}
+
+// dryRun fetches and parses the feed (or scrapes the website) without
+// changing anything.
+func dryRun(feedURL string, scr *scrape) (*gofeed.Feed, error) {
+	if scr != nil {
+		if err := scr.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	fd := &feed{
+		URL:    feedURL,
+		Scrape: scr,
+		log:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	parsed, err := fd.fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	if scr != nil && len(parsed.Items) == 0 {
+		return nil, ErrScrapeNoItems
+	}
+
+	return parsed, nil
+}
+
+// ValidateConfig dry runs every feed in the given config file, writing a
+// line per feed. Returns ErrFeedsNotValid if any failed.
+func ValidateConfig(configFilename string, w io.Writer) error {
+	f := &feeds{}
+	if err := f.readFeedsFile(configFilename); err != nil {
+		return err
+	}
+
+	all := f.list.All()
+	results := make([]string, len(all))
+	failed := 0
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		sem = make(chan struct{}, validateConcurrency)
+	)
+
+	for idx, fd := range all {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			parsed, err := dryRun(fd.URL, fd.Scrape)
+			<-sem
+
+			if err != nil {
+				mu.Lock()
+				failed++
+				mu.Unlock()
+
+				results[idx] = fmt.Sprintf("FAIL %s - %v", fd.URL, err)
+
+				return
+			}
+
+			results[idx] = fmt.Sprintf("ok   %s - %q, %d items", fd.URL, parsed.Title, len(parsed.Items))
+		}()
+	}
+
+	wg.Wait()
+
+	for _, line := range results {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return fmt.Errorf("error writing results - %w", err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d %w", failed, len(all), ErrFeedsNotValid)
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package rssole
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newValidateTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /rss", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8" ?>
+<rss version="2.0">
+<channel>
+  <title>Valid Feed</title>
+  <item><title>Item 1</title><link>http://example.com/1</link></item>
+  <item><title>Item 2</title><link>http://example.com/2</link></item>
+</channel>
+</rss>`)
+	})
+	mux.HandleFunc("GET /page", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `<html><body>
+<div class="item"><p class="title">Scraped 1</p><a class="link" href="http://example.com/s1">more</a></div>
+</body></html>`)
+	})
+
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestScrapeValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     scrape
+		expected error
+	}{
+		{"valid", scrape{URLs: []string{"http://example.com"}, Item: ".item", Title: ".title"}, nil},
+		{"no urls", scrape{URLs: []string{""}, Item: ".item", Title: ".title"}, ErrScrapeNoURLs},
+		{"no item", scrape{URLs: []string{"http://example.com"}, Title: ".title"}, ErrScrapeNoSelector},
+		{"bad title", scrape{URLs: []string{"http://example.com"}, Item: ".item", Title: "[["}, ErrScrapeSelector},
+		{"bad link", scrape{URLs: []string{"http://example.com"}, Item: ".item", Title: ".title", Link: ">>>"}, ErrScrapeSelector},
+		{"bad author", scrape{URLs: []string{"http://example.com"}, Item: ".item", Title: ".title", Author: "[["}, ErrScrapeSelector},
+	}
+
+	for _, tt := range tests {
+		if err := tt.conf.Validate(); !errors.Is(err, tt.expected) {
+			t.Fatal(tt.name, "expected", tt.expected, "got", err)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	ts := newValidateTestServer(t)
+
+	parsed, err := dryRun(ts.URL+"/rss", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Title != "Valid Feed" || len(parsed.Items) != 2 {
+		t.Fatal("unexpected feed", parsed)
+	}
+
+	if _, err := dryRun(ts.URL+"/page", nil); err == nil {
+		t.Fatal("expected html not to parse as a feed")
+	}
+
+	if _, err := dryRun(ts.URL+"/missing", nil); err == nil {
+		t.Fatal("expected an error for a missing feed")
+	}
+}
+
+func TestDryRun_Scrape(t *testing.T) {
+	ts := newValidateTestServer(t)
+
+	parsed, err := dryRun(ts.URL, &scrape{URLs: []string{ts.URL + "/page"}, Item: ".item", Title: ".title", Link: ".link"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parsed.Items) != 1 || parsed.Items[0].Title != "Scraped 1" {
+		t.Fatal("unexpected items", parsed.Items)
+	}
+
+	_, err = dryRun(ts.URL, &scrape{URLs: []string{ts.URL + "/page"}, Item: ".nothing", Title: ".title"})
+	if !errors.Is(err, ErrScrapeNoItems) {
+		t.Fatal("expected no items to be an error, got", err)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	ts := newValidateTestServer(t)
+
+	filename := filepath.Join(t.TempDir(), "rssole.json")
+
+	err := os.WriteFile(filename, []byte(`{"config":{},"feeds":[
+		{"url":"`+ts.URL+`/rss"},
+		{"url":"`+ts.URL+`/missing"}
+	]}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+
+	err = ValidateConfig(filename, &out)
+	if !errors.Is(err, ErrFeedsNotValid) {
+		t.Fatal("expected a failure, got", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ok") || !strings.HasPrefix(lines[1], "FAIL") {
+		t.Fatal("unexpected output", out.String())
+	}
+}
+
+func TestCrudFeed_Post_Preview(t *testing.T) {
+	defer setUpTearDown(t)(t)
+
+	ts := newValidateTestServer(t)
+	currentNumFeeds := len(testService.feeds.All())
+
+	data := url.Values{}
+	data.Add("url", ts.URL+"/rss")
+	data.Add("name", "Previewed")
+
+	req, err := http.NewRequest(http.MethodPost, "/crudfeed", strings.NewReader(data.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(testService.crudfeedPost).ServeHTTP(rr, req)
+
+	if len(testService.feeds.All()) != currentNumFeeds {
+		t.Fatal("expected nothing to be saved before confirming")
+	}
+
+	for _, expected := range []string{"Valid Feed", "Item 1", `name="name" value="Previewed"`, `name="confirmed" value="true"`} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Fatal("expected", expected, "in", rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package rssole
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// Webhooks POST new items, one request per item, to other services
+// (e.g. a chat). Each webhook can be limited to some feeds, categories
+// or keywords.
+
+const (
+	webhookSignatureHeader = "X-Rssole-Signature"
+	webhookMaxAttempts     = 5
+)
+
+var (
+	ErrWebhookStatus = errors.New("webhook returned an error status")
+
+	webhookFirstBackoff = 2 * time.Second // doubles after every failed attempt
+
+	webhookClient = &http.Client{
+		Timeout: httpClientTimeout,
+	}
+)
+
+type webhook struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret,omitempty"`     // signs the payload, see webhookSignatureHeader
+	Feeds      []string `json:"feeds,omitempty"`      // feed urls, empty for all
+	Categories []string `json:"categories,omitempty"` // empty for all
+	Keywords   []string `json:"keywords,omitempty"`   // case insensitive, in the title or summary, empty for all
+}
+
+type webhookFeed struct {
+	URL      string `json:"url"`
+	Title    string `json:"title"`
+	Category string `json:"category,omitempty"`
+}
+
+type webhookPayload struct {
+	Feed      webhookFeed `json:"feed"`
+	Title     string      `json:"title"`
+	Link      string      `json:"link"`
+	Summary   string      `json:"summary"`
+	Images    []string    `json:"images"`
+	Published *time.Time  `json:"published,omitempty"`
+}
+
+// Wants returns true if the webhook should be told about the item.
+func (wh *webhook) Wants(f *feed, i *wrappedItem) bool {
+	if len(wh.Feeds) > 0 && !slices.Contains(wh.Feeds, f.URL) {
+		return false
+	}
+
+	if len(wh.Categories) > 0 && !slices.Contains(wh.Categories, f.Category) {
+		return false
+	}
+
+	if len(wh.Keywords) == 0 {
+		return true
+	}
+
+	text := strings.ToLower(i.Title + " " + i.Summary())
+
+	for _, keyword := range wh.Keywords {
+		if strings.Contains(text, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// webhookSignature is the hex HMAC-SHA256 of the body, so receivers can
+// check the payload came from us.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookNotifier sends new items to the configured webhooks.
+type webhookNotifier struct {
+	config *ConfigSection
+}
+
+// Ensure webhookNotifier implements Notifier.
+var _ Notifier = (*webhookNotifier)(nil)
+
+// NewItems posts each item to every webhook that wants it. Delivery
+// happens in the background so a slow webhook can't hold up the feed.
+func (n *webhookNotifier) NewItems(f *feed, items []*wrappedItem) {
+	if len(n.config.Webhooks) == 0 {
+		return
+	}
+
+	feedInfo := webhookFeed{
+		URL:      f.URL,
+		Title:    f.Title(),
+		Category: f.Category,
+	}
+
+	for _, i := range items {
+		payload := webhookPayload{
+			Feed:      feedInfo,
+			Title:     i.Title,
+			Link:      i.Link,
+			Summary:   i.Summary(),
+			Images:    i.Images(),
+			Published: i.PublishedParsed,
+		}
+
+		body, err := json.Marshal(payload)
+		if err != nil {
+			slog.Error("error marshaling webhook payload", "error", err)
+
+			continue
+		}
+
+		for _, wh := range n.config.Webhooks {
+			if wh.Wants(f, i) {
+				go wh.deliver(body)
+			}
+		}
+	}
+}
+
+// deliver posts the body, retrying with backoff if it fails.
+func (wh *webhook) deliver(body []byte) {
+	backoff := webhookFirstBackoff
+
+	for attempt := 1; ; attempt++ {
+		retry, err := wh.post(body)
+		if err == nil {
+			return
+		}
+
+		if !retry || attempt == webhookMaxAttempts {
+			slog.Error("webhook failed", "url", wh.URL, "attempts", attempt, "error", err)
+
+			return
+		}
+
+		slog.Info("webhook failed, retrying", "url", wh.URL, "attempt", attempt, "backoff", backoff, "error", err)
+		time.Sleep(backoff)
+
+		backoff *= 2
+	}
+}
+
+// post makes one attempt at delivery, returning whether a failure is worth retrying.
+func (wh *webhook) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("cannot create new request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "rssole/"+Version)
+
+	if wh.Secret != "" {
+		req.Header.Set(webhookSignatureHeader, webhookSignature(wh.Secret, body))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("unable to do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// only server errors and rate limiting are likely to go away
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+
+		return retry, fmt.Errorf("%w - %s", ErrWebhookStatus, resp.Status)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,211 @@
+package rssole
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func TestWebhook_Wants(t *testing.T) {
+	f := &feed{URL: "http://example.com/releases.atom", Category: "Releases"}
+	i := &wrappedItem{Feed: f, Item: &gofeed.Item{Title: "v1.2.3 released"}}
+
+	tests := []struct {
+		webhook *webhook
+		wants   bool
+	}{
+		{&webhook{}, true},
+		{&webhook{Feeds: []string{f.URL}}, true},
+		{&webhook{Feeds: []string{"http://example.com/other"}}, false},
+		{&webhook{Categories: []string{"Releases"}}, true},
+		{&webhook{Categories: []string{"News"}}, false},
+		{&webhook{Keywords: []string{"RELEASED"}}, true},
+		{&webhook{Keywords: []string{"security"}}, false},
+	}
+
+	for _, tt := range tests {
+		if tt.webhook.Wants(f, i) != tt.wants {
+			t.Errorf("unexpected Wants for %+v: want %v", tt.webhook, tt.wants)
+		}
+	}
+}
+
+type webhookTestReceiver struct {
+	mu         sync.Mutex
+	requests   int
+	payloads   []webhookPayload
+	signatures []string
+	failFirst  int
+}
+
+func (r *webhookTestReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests++
+	if r.requests <= r.failFirst {
+		w.WriteHeader(http.StatusServiceUnavailable)
+
+		return
+	}
+
+	body, _ := io.ReadAll(req.Body)
+
+	var payload webhookPayload
+	_ = json.Unmarshal(body, &payload)
+
+	r.payloads = append(r.payloads, payload)
+	r.signatures = append(r.signatures, req.Header.Get(webhookSignatureHeader))
+}
+
+func (r *webhookTestReceiver) waitForPayloads(t *testing.T, n int) []webhookPayload {
+	t.Helper()
+
+	for range 200 {
+		r.mu.Lock()
+		got := len(r.payloads)
+		r.mu.Unlock()
+
+		if got >= n {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+
+			return r.payloads
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatal("timed out waiting for webhook payloads")
+
+	return nil
+}
+
+func TestWebhookNotifier_DeliversSignedPayload(t *testing.T) {
+	receiver := &webhookTestReceiver{failFirst: 2}
+	ts := httptest.NewServer(receiver)
+
+	defer ts.Close()
+
+	defer func(orig time.Duration) { webhookFirstBackoff = orig }(webhookFirstBackoff)
+	webhookFirstBackoff = time.Millisecond
+
+	notifier := &webhookNotifier{config: &ConfigSection{Webhooks: []*webhook{
+		{URL: ts.URL, Secret: "shh"},
+	}}}
+
+	f := &feed{URL: "http://example.com/feed", Name: "Example"}
+	item := &wrappedItem{Feed: f, Item: &gofeed.Item{Title: "Title 1", Link: "http://example.com/1"}}
+
+	notifier.NewItems(f, []*wrappedItem{item})
+
+	payloads := receiver.waitForPayloads(t, 1)
+
+	if payloads[0].Title != "Title 1" || payloads[0].Feed.Title != "Example" || payloads[0].Link != "http://example.com/1" {
+		t.Fatal("unexpected payload", payloads[0])
+	}
+
+	body, _ := json.Marshal(payloads[0])
+	if receiver.signatures[0] != webhookSignature("shh", body) {
+		t.Fatal("unexpected signature", receiver.signatures[0])
+	}
+
+	if receiver.requests != 3 {
+		t.Fatal("expected 2 failed attempts before success, got", receiver.requests)
+	}
+}
+
+// webhookTestNotifier records new items.
+type webhookTestNotifier struct {
+	items []*wrappedItem
+}
+
+func (n *webhookTestNotifier) NewItems(_ *feed, items []*wrappedItem) {
+	n.items = append(n.items, items...)
+}
+
+func TestUpdate_NotifiesNewItems(t *testing.T) {
+	items := `<item><title>Title 1</title><link>http://title1.com/</link></item>`
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8" ?>
+<rss version="2.0"><channel><title>Feed Title</title>`+items+`</channel></rss>`)
+	}))
+	defer ts.Close()
+
+	notifier := &webhookTestNotifier{}
+
+	feed := &feed{
+		URL:       ts.URL,
+		readCache: &mockReadCache{},
+		activity:  &mockActivityTracker{},
+		notifier:  notifier,
+	}
+	feed.Init()
+
+	if err := feed.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(notifier.items) != 0 {
+		t.Fatal("expected nothing to be new on the first update")
+	}
+
+	items += `<item><title>Title 2</title><link>http://title2.com/</link></item>`
+
+	if err := feed.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(notifier.items) != 1 || notifier.items[0].Title != "Title 2" {
+		t.Fatal("expected Title 2 to be new", notifier.items)
+	}
+}
+
+func TestUpdateFeed_NewURLNotifiesNothing(t *testing.T) {
+	newServer := func(items string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8" ?>
+<rss version="2.0"><channel><title>Feed Title</title>`+items+`</channel></rss>`)
+		}))
+	}
+
+	oldTS := newServer(`<item><title>Old</title><link>http://old.com/</link></item>`)
+	defer oldTS.Close()
+
+	newTS := newServer(`<item><title>New</title><link>http://new.com/</link></item>`)
+	defer newTS.Close()
+
+	svc := NewService()
+	notifier := &webhookTestNotifier{}
+
+	f := &feed{
+		URL:       oldTS.URL,
+		readCache: &mockReadCache{},
+		activity:  &mockActivityTracker{},
+		notifier:  notifier,
+	}
+	f.Init()
+	svc.feeds.list.Add(f)
+
+	if err := f.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	svc.updateFeed(f.ID(), &feed{URL: newTS.URL})
+
+	if err := f.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(notifier.items) != 0 {
+		t.Fatal("expected a new url's items not to be sent as new", notifier.items)
+	}
+}